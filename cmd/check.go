@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"io/ioutil"
 	"os"
 	"path"
@@ -28,11 +29,17 @@ var checkCmd = &cobra.Command{
 			log.Error("Unable to parse checks.yml: " + err.Error())
 			return
 		}
-		downCounter := make(map[string]int)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		pool := lib.NewWorkerPool(config.Server.MaxConcurrency, config.Server.MaxPerHost)
+		pool.Start(ctx)
+		store := lib.NewIncidentStore()
 		failed, successful, total := lib.RunChecks(
+			ctx,
+			pool,
 			config.Checks,
 			&config.ExportedReporters,
-			downCounter)
+			store)
 		if failed >= successful {
 			log.Warnf("Failed/Successful/Total: %d/%d/%d", failed, successful, total)
 		} else {
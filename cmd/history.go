@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"text/tabwriter"
+	"time"
+
+	"github.com/KixPanganiban/bantay/lib"
+	"github.com/KixPanganiban/bantay/log"
+	"github.com/hako/durafmt"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historySince string
+	historyLimit int
+)
+
+// historyCmd prints the recent results, incidents and latency percentiles of a check
+var historyCmd = &cobra.Command{
+	Use:   "history <check>",
+	Short: "Print the history of a check",
+	Long:  `Prints the recent results, incidents and latency percentiles of a check, from the history kept by the server under server.history in checks.yml.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, _ := os.Getwd()
+		checksFilePath := path.Join(dir, "checks.yml")
+		checksFileBytes, err := ioutil.ReadFile(checksFilePath)
+		if err != nil {
+			log.Error("Unable to open checks.yml")
+			return
+		}
+		config, err := lib.ParseYAML(checksFileBytes)
+		if err != nil {
+			log.Error("Unable to parse checks.yml: " + err.Error())
+			return
+		}
+		if len(config.Server.History.Path) == 0 {
+			log.Error("No history is kept, as server.history.path isn't set in checks.yml")
+			return
+		}
+		since, err := lib.ParseDuration(historySince)
+		if err != nil {
+			log.Error("Unable to parse --since: " + err.Error())
+			return
+		}
+		name := args[0]
+		history := lib.NewHistoryStore(config.Server.History)
+		records, err := history.Results(name, time.Now().Add(-since))
+		if err != nil {
+			log.Error("Unable to read history: " + err.Error())
+			return
+		}
+		incidents, err := history.Incidents(name, time.Now().Add(-since))
+		if err != nil {
+			log.Error("Unable to read history: " + err.Error())
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "Results of %s in the last %s (%d total):\n", name, historySince, len(records))
+		fmt.Fprintln(w, "TIME\tSTATUS\tLATENCY\tATTEMPTS\tMESSAGE")
+		shown := records
+		if historyLimit > 0 && len(shown) > historyLimit {
+			shown = shown[len(shown)-historyLimit:]
+		}
+		for _, record := range shown {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n",
+				record.Time.Format(time.RFC3339),
+				record.Result.Status(),
+				record.Result.Latency.Round(time.Millisecond),
+				record.Result.Attempts,
+				record.Result.Message)
+		}
+
+		fmt.Fprintf(w, "\nIncidents (%d total):\n", len(incidents))
+		fmt.Fprintln(w, "START\tEND\tDOWNTIME\tFAILED CHECKS\tFIRST FAILURE")
+		for _, incident := range incidents {
+			end, downtime := "ongoing", time.Since(incident.Start)
+			if !incident.End.IsZero() {
+				end, downtime = incident.End.Format(time.RFC3339), incident.End.Sub(incident.Start)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n",
+				incident.Start.Format(time.RFC3339),
+				end,
+				durafmt.Parse(downtime.Round(time.Second)).String(),
+				incident.FailedCount,
+				incident.FirstFailure.Message)
+		}
+
+		latencies := make([]time.Duration, 0, len(records))
+		for _, record := range records {
+			if record.Result.Success {
+				latencies = append(latencies, record.Result.Latency)
+			}
+		}
+		fmt.Fprintf(w, "\nLatency of %d successful results:\n", len(latencies))
+		fmt.Fprintln(w, "P50\tP95\tP99")
+		fmt.Fprintf(w, "%s\t%s\t%s\n",
+			lib.Percentile(latencies, 50).Round(time.Millisecond),
+			lib.Percentile(latencies, 95).Round(time.Millisecond),
+			lib.Percentile(latencies, 99).Round(time.Millisecond))
+		w.Flush()
+	},
+}
+
+func init() {
+	historyCmd.Flags().StringVar(&historySince, "since", "24h", "How far back to look, like 6h or 30d")
+	historyCmd.Flags().IntVar(&historyLimit, "limit", 20, "Most recent results to print, or 0 for all")
+	rootCmd.AddCommand(historyCmd)
+}
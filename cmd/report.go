@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"github.com/KixPanganiban/bantay/lib"
+	"github.com/KixPanganiban/bantay/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reportSince  string
+	reportUntil  string
+	reportFormat string
+)
+
+// reportCmd prints the uptime, incidents and latency of every check over a period
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Print an uptime report of every check",
+	Long:  `Prints the uptime percent, incident count, MTTR, MTBF and latency percentiles of every check in checks.yml over a period, from the history kept by the server under server.history.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, _ := os.Getwd()
+		checksFilePath := path.Join(dir, "checks.yml")
+		checksFileBytes, err := ioutil.ReadFile(checksFilePath)
+		if err != nil {
+			log.Error("Unable to open checks.yml")
+			return
+		}
+		config, err := lib.ParseYAML(checksFileBytes)
+		if err != nil {
+			log.Error("Unable to parse checks.yml: " + err.Error())
+			return
+		}
+		if len(config.Server.History.Path) == 0 {
+			log.Error("No history is kept, as server.history.path isn't set in checks.yml")
+			return
+		}
+		now := time.Now()
+		since, err := lib.ParseReportTime(reportSince, now)
+		if err != nil {
+			log.Error("Unable to parse --since: " + err.Error())
+			return
+		}
+		until := now
+		if len(reportUntil) > 0 {
+			if until, err = lib.ParseReportTime(reportUntil, now); err != nil {
+				log.Error("Unable to parse --until: " + err.Error())
+				return
+			}
+		}
+		if until.After(now) {
+			until = now
+		}
+		if !since.Before(until) {
+			log.Error("--since must be before --until")
+			return
+		}
+		report, err := lib.NewReport(
+			lib.NewHistoryStore(config.Server.History),
+			*config.Checks,
+			since,
+			until)
+		if err != nil {
+			log.Error("Unable to read history: " + err.Error())
+			return
+		}
+		if err := report.Write(os.Stdout, reportFormat); err != nil {
+			log.Error("Unable to write report: " + err.Error())
+		}
+	},
+}
+
+func init() {
+	reportCmd.Flags().StringVar(&reportSince, "since", "30d", "Start of the report, as a date like 2006-01-02 or how far back, like 24h or 30d")
+	reportCmd.Flags().StringVar(&reportUntil, "until", "", "End of the report, as a date like 2006-01-02 or how far back, like 24h or 7d (defaults to now)")
+	reportCmd.Flags().StringVar(&reportFormat, "format", "markdown", "Format of the report, one of markdown, html, csv or json")
+	rootCmd.AddCommand(reportCmd)
+}
@@ -1,10 +1,14 @@
 package cmd
 
 import (
+	"context"
 	"io/ioutil"
+	"net/http"
 	"os"
+	"os/signal"
 	"path"
-	"time"
+	"sync"
+	"syscall"
 
 	"github.com/KixPanganiban/bantay/lib"
 	"github.com/KixPanganiban/bantay/log"
@@ -30,21 +34,101 @@ var serverCmd = &cobra.Command{
 			log.Error("Unable to parse checks.yml: " + err.Error())
 			return
 		}
-		downCounter := make(map[string]int)
-		for true {
-			log.Debugln("Running checks...")
-			failed, successful, total := lib.RunChecks(
-				config.Checks,
-				&config.ExportedReporters,
-				downCounter)
-			if failed >= successful {
-				log.Warnf("Failed/Successful/Total: %d/%d/%d", failed, successful, total)
-			} else {
-				log.Infof("Failed/Successful/Total: %d/%d/%d", failed, successful, total)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-signals
+			log.Infoln("Shutting down server...")
+			cancel()
+		}()
+		store := lib.NewIncidentStore()
+		// background runs the goroutines that save state and history, which need a last save on
+		// shutdown, and serve the status API and metrics
+		var background sync.WaitGroup
+		if len(config.Server.State.Type) > 0 {
+			backend, err := lib.NewStateBackend(config.Server.State)
+			if err != nil {
+				log.Error("Unable to open state: " + err.Error())
+				return
 			}
-			log.Debugf("Sleeping for %d seconds.\n", config.Server.PollInterval)
-			time.Sleep(time.Duration(config.Server.PollInterval) * time.Second)
+			defer backend.Close()
+			states, err := backend.Load()
+			if err != nil {
+				log.Error("Unable to load state: " + err.Error())
+				return
+			}
+			store.Restore(states, *config.Checks)
+			log.Infof("Loaded state of %d checks from %s.", len(states), config.Server.State.Path)
+			background.Add(1)
+			go func() {
+				defer background.Done()
+				lib.PersistState(ctx, store, backend, config.Server.State.SaveInterval)
+			}()
+		}
+		reporters := config.ExportedReporters
+		var history *lib.HistoryStore
+		if len(config.Server.History.Path) > 0 {
+			history = lib.NewHistoryStore(config.Server.History)
+			reporters = append(reporters, history)
+			background.Add(1)
+			go func() {
+				defer background.Done()
+				history.Run(ctx, config.Server.History.FlushInterval)
+			}()
+		}
+		if len(config.Server.Listen) > 0 {
+			mux := http.NewServeMux()
+			lib.StatusAPI{Checks: *config.Checks, Store: store, History: history}.Register(mux)
+			lib.NewBadges(*config.Checks, store, history).Register(mux)
+			for _, reporter := range config.ExportedReporters {
+				if pr, ok := reporter.(lib.PrometheusReporter); ok && len(pr.PrometheusListen) == 0 {
+					pr.Register(mux)
+				}
+			}
+			if config.Server.StatusPage.Enabled {
+				lib.NewStatusPage(config.Server.StatusPage, *config.Checks, store, history).Register(mux)
+			}
+			background.Add(1)
+			go func() {
+				defer background.Done()
+				log.Infof("Serving status API, page and badges on %s.", config.Server.Listen)
+				if err := lib.Listen(ctx, config.Server.Listen, mux); err != nil {
+					log.Error("Unable to serve status API: " + err.Error())
+					cancel()
+				}
+			}()
+		}
+		for _, reporter := range config.ExportedReporters {
+			pr, ok := reporter.(lib.PrometheusReporter)
+			if !ok || len(pr.PrometheusListen) == 0 {
+				continue
+			}
+			mux := http.NewServeMux()
+			pr.Register(mux)
+			background.Add(1)
+			go func() {
+				defer background.Done()
+				log.Infof("Serving Prometheus metrics on %s%s.", pr.PrometheusListen, pr.PrometheusPath)
+				if err := lib.Listen(ctx, pr.PrometheusListen, mux); err != nil {
+					log.Error("Unable to serve Prometheus metrics: " + err.Error())
+					cancel()
+				}
+			}()
 		}
+		scheduler := lib.NewScheduler(
+			*config.Checks,
+			lib.NewWorkerPool(config.Server.MaxConcurrency, config.Server.MaxPerHost),
+			reporters,
+			store,
+			config.Server.Jitter)
+		log.Infof("Scheduling %d checks.", len(*config.Checks))
+		scheduler.Run(ctx)
+		// Wait for the last save of state and history, and the listener to shut down, before
+		// closing the state backend
+		cancel()
+		background.Wait()
 	},
 }
 
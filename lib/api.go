@@ -0,0 +1,197 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KixPanganiban/bantay/log"
+)
+
+// DefaultAPIHistorySince is how far back the history endpoint looks when not given since
+const DefaultAPIHistorySince = 24 * time.Hour
+
+// CheckStatus is the current state of a check as served by the StatusAPI
+type CheckStatus struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// State is one of up, degraded or down, or pending until the check first ran
+	State          string       `json:"state"`
+	LastTransition *time.Time   `json:"last_transition"`
+	LastChecked    *time.Time   `json:"last_checked"`
+	LastResult     *CheckResult `json:"last_result"`
+	// LatencyMs is the latency of the last result, in milliseconds
+	LatencyMs int64 `json:"latency_ms"`
+	// IncidentStart is when the ongoing incident of a check that went down started, if any
+	IncidentStart *time.Time `json:"incident_start"`
+	// Incident is the ongoing incident, only served for a single check
+	Incident *Incident `json:"incident,omitempty"`
+}
+
+// StatusAPI serves the state and history of every check that isn't Internal as JSON
+type StatusAPI struct {
+	Checks []Check
+	Store  *IncidentStore
+	// History is used for the history endpoint, which isn't served when it is nil
+	History *HistoryStore
+}
+
+// Register adds the endpoints of the StatusAPI to the mux:
+//
+//	GET /api/checks                   every check with its current state
+//	GET /api/checks/<name>            a check with its current state and ongoing incident
+//	GET /api/checks/<name>/history    results and incidents of a check, since ?since=24h
+func (api StatusAPI) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/api/checks", api.serveChecks)
+	mux.HandleFunc("/api/checks/", api.serveCheck)
+}
+
+// serveChecks serves every check with its current state
+func (api StatusAPI) serveChecks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	checks := make([]CheckStatus, 0, len(api.Checks))
+	for _, c := range api.Checks {
+		if c.Internal {
+			continue
+		}
+		checks = append(checks, api.status(c))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"checks": checks})
+}
+
+// serveCheck serves a single check, or its history
+func (api StatusAPI) serveCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/api/checks/")
+	history := strings.HasSuffix(name, "/history")
+	name = strings.TrimSuffix(name, "/history")
+	c, ok := api.check(name)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "no check named "+name)
+		return
+	}
+	if !history {
+		status := api.status(c)
+		if state, ok := api.Store.State(c.Name); ok {
+			status.Incident = state.Incident
+		}
+		writeJSON(w, http.StatusOK, status)
+		return
+	}
+	if api.History == nil {
+		writeJSONError(w, http.StatusNotFound, "no history is kept, as server history path isn't set")
+		return
+	}
+	since := DefaultAPIHistorySince
+	if s := r.URL.Query().Get("since"); len(s) > 0 {
+		var err error
+		if since, err = ParseDuration(s); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	limit := 0
+	if l := r.URL.Query().Get("limit"); len(l) > 0 {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit < 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid limit "+l)
+			return
+		}
+	}
+	records, err := api.History.Results(c.Name, time.Now().Add(-since))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if limit > 0 && len(records) > limit {
+		records = records[len(records)-limit:]
+	}
+	incidents, err := api.History.Incidents(c.Name, time.Now().Add(-since))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"name":      c.Name,
+		"results":   records,
+		"incidents": incidents,
+	})
+}
+
+// check returns the configured Check with the given name, unless it is Internal
+func (api StatusAPI) check(name string) (Check, bool) {
+	for _, c := range api.Checks {
+		if c.Name == name && !c.Internal {
+			return c, true
+		}
+	}
+	return Check{}, false
+}
+
+// status returns the current state of the Check
+func (api StatusAPI) status(c Check) CheckStatus {
+	checkType := c.Type
+	if len(checkType) == 0 {
+		checkType = "http"
+	}
+	status := CheckStatus{Name: c.Name, Type: checkType, State: "pending"}
+	state, ok := api.Store.State(c.Name)
+	if !ok {
+		return status
+	}
+	status.State = state.State
+	status.LastTransition = &state.LastTransition
+	status.LastChecked = &state.LastChecked
+	status.LastResult = &state.LastResult
+	status.LatencyMs = int64(state.LastResult.Latency / time.Millisecond)
+	if state.Incident != nil && state.Incident.Down {
+		status.IncidentStart = &state.Incident.Start
+	}
+	return status
+}
+
+// writeJSON writes v as the JSON body of a response with the given status code
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warnf("Unable to write response: %s", err.Error())
+	}
+}
+
+// writeJSONError writes an error message as the JSON body of a response with the given status code
+func writeJSONError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, map[string]string{"error": message})
+}
+
+// Listen serves the handler on the given address until the context is done, then shuts the
+// server down, giving in-flight requests a few seconds to finish
+func Listen(ctx context.Context, addr string, handler http.Handler) error {
+	server := &http.Server{
+		Addr:         addr,
+		Handler:      handler,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	}
+}
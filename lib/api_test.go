@@ -0,0 +1,151 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// serveJSON sends a request to the handler and decodes the JSON response into v
+func serveJSON(t *testing.T, handler http.Handler, method string, target string, v interface{}) int {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	if ct := w.Header().Get("Content-Type"); v != nil && ct != "application/json" {
+		t.Errorf("%s %s has Content-Type %q, want application/json", method, target, ct)
+	}
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Errorf("%s %s returned invalid JSON %q: %s", method, target, w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+// newTestAPI returns a mux serving the StatusAPI of checks a, which is down, and b, which never ran
+func newTestAPI(history *HistoryStore) *http.ServeMux {
+	checks := []Check{{Name: "a", Type: "tcp"}, {Name: "b"}}
+	store := NewIncidentStore()
+	store.Track(checks[0], CheckResult{Name: "a", Success: false, Reason: ReasonTimeout, Latency: 1500 * time.Millisecond})
+	mux := http.NewServeMux()
+	StatusAPI{Checks: checks, Store: store, History: history}.Register(mux)
+	return mux
+}
+
+func TestStatusAPIChecks(t *testing.T) {
+	mux := newTestAPI(nil)
+	var body struct {
+		Checks []CheckStatus `json:"checks"`
+	}
+	if code := serveJSON(t, mux, "GET", "/api/checks", &body); code != http.StatusOK {
+		t.Fatalf("GET /api/checks returned %d, want 200", code)
+	}
+	if len(body.Checks) != 2 {
+		t.Fatalf("GET /api/checks returned %d checks, want 2", len(body.Checks))
+	}
+	a, b := body.Checks[0], body.Checks[1]
+	if a.Name != "a" || a.Type != "tcp" || a.State != "down" || a.LatencyMs != 1500 || a.IncidentStart == nil || a.LastResult == nil {
+		t.Errorf("GET /api/checks returned %+v for a, want it down since its incident started", a)
+	}
+	if b.Name != "b" || b.Type != "http" || b.State != "pending" || b.LastChecked != nil {
+		t.Errorf("GET /api/checks returned %+v for b, want it pending", b)
+	}
+	if code := serveJSON(t, mux, "POST", "/api/checks", nil); code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /api/checks returned %d, want 405", code)
+	}
+}
+
+func TestStatusAPICheck(t *testing.T) {
+	mux := newTestAPI(nil)
+	var status CheckStatus
+	if code := serveJSON(t, mux, "GET", "/api/checks/a", &status); code != http.StatusOK {
+		t.Fatalf("GET /api/checks/a returned %d, want 200", code)
+	}
+	if status.State != "down" || status.Incident == nil || status.Incident.FirstFailure.Reason != ReasonTimeout {
+		t.Errorf("GET /api/checks/a = %+v, want it down with its incident", status)
+	}
+	var body map[string]string
+	if code := serveJSON(t, mux, "GET", "/api/checks/missing", &body); code != http.StatusNotFound || len(body["error"]) == 0 {
+		t.Errorf("GET /api/checks/missing returned %d %v, want 404 with an error", code, body)
+	}
+	if code := serveJSON(t, mux, "GET", "/api/checks/a/history", &body); code != http.StatusNotFound {
+		t.Errorf("GET /api/checks/a/history without history returned %d, want 404", code)
+	}
+}
+
+func TestStatusAPIHistory(t *testing.T) {
+	hs, dir := newTestHistoryStore(t)
+	defer os.RemoveAll(dir)
+	now := time.Now()
+	hs.results = []HistoryRecord{
+		{Time: now.Add(-48 * time.Hour), Result: CheckResult{Name: "a", Success: true}},
+		{Time: now.Add(-2 * time.Hour), Result: CheckResult{Name: "a", Success: true}},
+		{Time: now.Add(-time.Hour), Result: CheckResult{Name: "a", Success: false}},
+	}
+	hs.Flush()
+	mux := newTestAPI(hs)
+	tests := []struct {
+		target  string
+		results int
+	}{
+		{"/api/checks/a/history", 2},
+		{"/api/checks/a/history?since=3d", 3},
+		{"/api/checks/a/history?since=3d&limit=1", 1},
+	}
+	for _, tt := range tests {
+		var body struct {
+			Name    string          `json:"name"`
+			Results []HistoryRecord `json:"results"`
+		}
+		if code := serveJSON(t, mux, "GET", tt.target, &body); code != http.StatusOK {
+			t.Errorf("GET %s returned %d, want 200", tt.target, code)
+			continue
+		}
+		if body.Name != "a" || len(body.Results) != tt.results {
+			t.Errorf("GET %s returned %d results, want %d", tt.target, len(body.Results), tt.results)
+		}
+	}
+	limited := struct {
+		Results []HistoryRecord `json:"results"`
+	}{}
+	serveJSON(t, mux, "GET", "/api/checks/a/history?since=3d&limit=1", &limited)
+	if len(limited.Results) == 1 && limited.Results[0].Result.Success {
+		t.Error("GET with limit=1 returned an older result, want the latest")
+	}
+	for _, target := range []string{"/api/checks/a/history?since=soon", "/api/checks/a/history?limit=-1"} {
+		if code := serveJSON(t, mux, "GET", target, nil); code != http.StatusBadRequest {
+			t.Errorf("GET %s returned %d, want 400", target, code)
+		}
+	}
+}
+
+func TestStatusAPIHidesInternalChecks(t *testing.T) {
+	hs, dir := newTestHistoryStore(t)
+	defer os.RemoveAll(dir)
+	checks := []Check{{Name: "public"}, {Name: "secret", Internal: true}}
+	store := NewIncidentStore()
+	for _, c := range checks {
+		hs.Report(context.Background(), store.Track(c, CheckResult{Name: c.Name, Success: true}))
+	}
+	hs.Flush()
+	mux := http.NewServeMux()
+	StatusAPI{Checks: checks, Store: store, History: hs}.Register(mux)
+	var body struct {
+		Checks []CheckStatus `json:"checks"`
+	}
+	serveJSON(t, mux, "GET", "/api/checks", &body)
+	if len(body.Checks) != 1 || body.Checks[0].Name != "public" {
+		t.Errorf("GET /api/checks = %+v, want only the public check", body.Checks)
+	}
+	for _, target := range []string{"/api/checks/secret", "/api/checks/secret/history"} {
+		if code := serveJSON(t, mux, "GET", target, nil); code != http.StatusNotFound {
+			t.Errorf("GET %s returned %d, want 404", target, code)
+		}
+	}
+	if code := serveJSON(t, mux, "GET", "/api/checks/public/history", nil); code != http.StatusOK {
+		t.Errorf("GET /api/checks/public/history returned %d, want 200", code)
+	}
+}
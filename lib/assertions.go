@@ -0,0 +1,418 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tidwall/gjson"
+)
+
+// StringList is a list of strings that can also be unmarshalled from a single string
+type StringList []string
+
+// UnmarshalYAML accepts a single string or a list of strings
+func (sl *StringList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var single string
+	if err := unmarshal(&single); err == nil {
+		*sl = StringList{single}
+		return nil
+	}
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		return err
+	}
+	*sl = list
+	return nil
+}
+
+var (
+	regexpCache   = map[string]*regexp.Regexp{}
+	regexpCacheMu sync.Mutex
+)
+
+// compileRegexp compiles the pattern once and reuses it across checks
+func compileRegexp(pattern string) (*regexp.Regexp, error) {
+	regexpCacheMu.Lock()
+	defer regexpCacheMu.Unlock()
+	if re, ok := regexpCache[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexpCache[pattern] = re
+	return re, nil
+}
+
+// validateAssertions ensures every response assertion of the Check is usable
+func validateAssertions(c Check) error {
+	for _, pattern := range c.BodyRegex {
+		if _, err := compileRegexp(pattern); err != nil {
+			return fmt.Errorf("invalid body_regex %s: %s", pattern, err.Error())
+		}
+	}
+	for _, ja := range c.JSONAssertions {
+		if err := ja.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, ha := range c.HeaderAssertions {
+		if err := ha.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasBodyAssertions returns true if the Check needs the response body to be read
+func hasBodyAssertions(c Check) bool {
+	return len(c.BodyMatch) > 0 || len(c.BodyRegex) > 0 || len(c.BodyNotContains) > 0 || len(c.JSONAssertions) > 0
+}
+
+// checkBody evaluates every body assertion of the Check against the response body, returning
+// the first failure, or the groups captured by body_regex on success
+func checkBody(c Check, body string) (bool, string) {
+	for _, match := range c.BodyMatch {
+		if !strings.Contains(body, match) {
+			return false, fmt.Sprintf("String '%s' not found in body.", match)
+		}
+	}
+	for _, notContains := range c.BodyNotContains {
+		if strings.Contains(body, notContains) {
+			return false, fmt.Sprintf("String '%s' found in body.", notContains)
+		}
+	}
+	var captured []string
+	for _, pattern := range c.BodyRegex {
+		re, err := compileRegexp(pattern)
+		if err != nil {
+			return false, fmt.Sprintf("Invalid body_regex '%s': %s", pattern, err.Error())
+		}
+		groups := re.FindStringSubmatch(body)
+		if groups == nil {
+			return false, fmt.Sprintf("Pattern '%s' not matched in body.", pattern)
+		}
+		names := re.SubexpNames()
+		for i := 1; i < len(groups); i++ {
+			name := names[i]
+			if len(name) == 0 {
+				name = strconv.Itoa(i)
+			}
+			captured = append(captured, fmt.Sprintf("%s=%s", name, groups[i]))
+		}
+	}
+	if len(c.JSONAssertions) > 0 {
+		if !gjson.Valid(body) {
+			return false, "Body is not valid JSON."
+		}
+		for _, ja := range c.JSONAssertions {
+			if ok, msg := ja.Evaluate(body); !ok {
+				return false, msg
+			}
+		}
+	}
+	if len(captured) > 0 {
+		return true, fmt.Sprintf("Captured %s.", strings.Join(captured, ", "))
+	}
+	return true, ""
+}
+
+// JSONAssertion compares the value found at a gjson path in a JSON response body with an expected value
+type JSONAssertion struct {
+	Path     string      `yaml:"path"`
+	Operator string      `yaml:"op"`
+	Value    interface{} `yaml:"value"`
+}
+
+// jsonOperators are the operators a JSONAssertion can use
+var jsonOperators = []string{"eq", "ne", "lt", "gt", "contains", "exists"}
+
+// Validate ensures the JSONAssertion has a path, a known operator and a usable expected value
+func (ja JSONAssertion) Validate() error {
+	if len(ja.Path) == 0 {
+		return errors.New("json_assertions path is required")
+	}
+	switch ja.Operator {
+	case "eq", "ne", "contains":
+		{
+			if ja.Value == nil && ja.Operator == "contains" {
+				return fmt.Errorf("json_assertions %s: value is required for %s", ja.Path, ja.Operator)
+			}
+		}
+	case "lt", "gt":
+		{
+			if _, ok := toFloat(ja.Value); !ok {
+				return fmt.Errorf("json_assertions %s: value must be a number for %s", ja.Path, ja.Operator)
+			}
+		}
+	case "exists":
+		{
+			if _, ok := ja.Value.(bool); ja.Value != nil && !ok {
+				return fmt.Errorf("json_assertions %s: value must be true or false for exists", ja.Path)
+			}
+		}
+	default:
+		return fmt.Errorf("json_assertions %s: unknown op %s, expected one of: %s", ja.Path, ja.Operator, strings.Join(jsonOperators, ", "))
+	}
+	return nil
+}
+
+// Evaluate applies the JSONAssertion to a JSON document, returning a message on failure
+func (ja JSONAssertion) Evaluate(body string) (bool, string) {
+	result := gjson.Get(body, ja.Path)
+	if ja.Operator == "exists" {
+		shouldExist, ok := ja.Value.(bool)
+		if !ok {
+			shouldExist = true
+		}
+		if result.Exists() != shouldExist {
+			if shouldExist {
+				return false, fmt.Sprintf("JSON path '%s' not found in body.", ja.Path)
+			}
+			return false, fmt.Sprintf("JSON path '%s' found in body.", ja.Path)
+		}
+		return true, ""
+	}
+	if !result.Exists() {
+		return false, fmt.Sprintf("JSON path '%s' not found in body.", ja.Path)
+	}
+	var ok bool
+	switch ja.Operator {
+	case "eq":
+		ok = jsonEquals(result, ja.Value)
+	case "ne":
+		ok = !jsonEquals(result, ja.Value)
+	case "lt", "gt":
+		{
+			expected, _ := toFloat(ja.Value)
+			if result.Type == gjson.Number {
+				ok = (ja.Operator == "lt" && result.Float() < expected) || (ja.Operator == "gt" && result.Float() > expected)
+			}
+		}
+	case "contains":
+		{
+			if result.IsArray() {
+				for _, item := range result.Array() {
+					if jsonEquals(item, ja.Value) {
+						ok = true
+						break
+					}
+				}
+			} else {
+				ok = strings.Contains(result.String(), fmt.Sprint(ja.Value))
+			}
+		}
+	}
+	if !ok {
+		return false, fmt.Sprintf("JSON assertion failed. Expected '%s' %s %v, got %s.", ja.Path, ja.Operator, ja.Value, result.Raw)
+	}
+	return true, ""
+}
+
+// jsonEquals compares a gjson result with a value unmarshalled from YAML
+func jsonEquals(result gjson.Result, v interface{}) bool {
+	if v == nil {
+		return result.Type == gjson.Null
+	}
+	if b, ok := v.(bool); ok {
+		return (result.Type == gjson.True && b) || (result.Type == gjson.False && !b)
+	}
+	if f, ok := toFloat(v); ok && result.Type == gjson.Number {
+		return result.Float() == f
+	}
+	return result.String() == fmt.Sprint(v)
+}
+
+// toFloat converts a number unmarshalled from YAML to a float64
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// StatusRange is an inclusive range of HTTP status codes
+type StatusRange struct {
+	Min int
+	Max int
+}
+
+// String formats the range as a single code, a class like 2xx, or a range like 200-299
+func (sr StatusRange) String() string {
+	if sr.Min == sr.Max {
+		return strconv.Itoa(sr.Min)
+	}
+	if sr.Min%100 == 0 && sr.Max == sr.Min+99 {
+		return fmt.Sprintf("%dxx", sr.Min/100)
+	}
+	return fmt.Sprintf("%d-%d", sr.Min, sr.Max)
+}
+
+// StatusCodes is the set of HTTP status codes accepted by a Check
+type StatusCodes []StatusRange
+
+var (
+	statusCodePattern  = regexp.MustCompile(`^([1-5][0-9]{2})$`)
+	statusClassPattern = regexp.MustCompile(`^([1-5])[xX]{2}$`)
+	statusRangePattern = regexp.MustCompile(`^([1-5][0-9]{2})\s*-\s*([1-5][0-9]{2})$`)
+)
+
+// UnmarshalYAML accepts a single status code, class or range, or a list of them
+func (sc *StatusCodes) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var parsed interface{}
+	if err := unmarshal(&parsed); err != nil {
+		return err
+	}
+	values, ok := parsed.([]interface{})
+	if !ok {
+		values = []interface{}{parsed}
+	}
+	*sc = make(StatusCodes, 0, len(values))
+	for _, v := range values {
+		sr, err := parseStatusRange(v)
+		if err != nil {
+			return err
+		}
+		*sc = append(*sc, sr)
+	}
+	return nil
+}
+
+// parseStatusRange parses a status code like 200, a class like 2xx, or a range like 200-299
+func parseStatusRange(v interface{}) (StatusRange, error) {
+	s := strings.TrimSpace(fmt.Sprint(v))
+	if m := statusCodePattern.FindStringSubmatch(s); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		return StatusRange{Min: code, Max: code}, nil
+	}
+	if m := statusClassPattern.FindStringSubmatch(s); m != nil {
+		class, _ := strconv.Atoi(m[1])
+		return StatusRange{Min: class * 100, Max: class*100 + 99}, nil
+	}
+	if m := statusRangePattern.FindStringSubmatch(s); m != nil {
+		min, _ := strconv.Atoi(m[1])
+		max, _ := strconv.Atoi(m[2])
+		if min > max {
+			return StatusRange{}, fmt.Errorf("invalid status range %s", s)
+		}
+		return StatusRange{Min: min, Max: max}, nil
+	}
+	return StatusRange{}, fmt.Errorf("invalid status %s, expected a code like 200, a class like 2xx or a range like 200-299", s)
+}
+
+// Matches returns true if the status code is accepted
+func (sc StatusCodes) Matches(status int) bool {
+	for _, sr := range sc {
+		if status >= sr.Min && status <= sr.Max {
+			return true
+		}
+	}
+	return false
+}
+
+// String lists the accepted status codes
+func (sc StatusCodes) String() string {
+	accepted := make([]string, len(sc))
+	for i, sr := range sc {
+		accepted[i] = sr.String()
+	}
+	return strings.Join(accepted, ", ")
+}
+
+// HeaderAssertion checks a header of an HTTP response
+type HeaderAssertion struct {
+	Name     string      `yaml:"name"`
+	Operator string      `yaml:"op"`
+	Value    interface{} `yaml:"value"`
+}
+
+// headerOperators are the operators a HeaderAssertion can use
+var headerOperators = []string{"exists", "equals", "regex"}
+
+// Validate ensures the HeaderAssertion has a name, a known operator and a usable expected value
+func (ha HeaderAssertion) Validate() error {
+	if len(ha.Name) == 0 {
+		return errors.New("header_assertions name is required")
+	}
+	switch ha.Operator {
+	case "exists":
+		{
+			if _, ok := ha.Value.(bool); ha.Value != nil && !ok {
+				return fmt.Errorf("header_assertions %s: value must be true or false for exists", ha.Name)
+			}
+		}
+	case "equals":
+		{
+			if ha.Value == nil {
+				return fmt.Errorf("header_assertions %s: value is required for equals", ha.Name)
+			}
+		}
+	case "regex":
+		{
+			if _, err := compileRegexp(fmt.Sprint(ha.Value)); ha.Value == nil || err != nil {
+				return fmt.Errorf("header_assertions %s: value must be a valid regular expression for regex", ha.Name)
+			}
+		}
+	default:
+		return fmt.Errorf("header_assertions %s: unknown op %s, expected one of: %s", ha.Name, ha.Operator, strings.Join(headerOperators, ", "))
+	}
+	return nil
+}
+
+// Evaluate applies the HeaderAssertion to the response headers, returning a message on failure
+func (ha HeaderAssertion) Evaluate(header http.Header) (bool, string) {
+	values := header[http.CanonicalHeaderKey(ha.Name)]
+	if ha.Operator == "exists" {
+		shouldExist, ok := ha.Value.(bool)
+		if !ok {
+			shouldExist = true
+		}
+		if (len(values) > 0) != shouldExist {
+			if shouldExist {
+				return false, fmt.Sprintf("Header '%s' not found in response.", ha.Name)
+			}
+			return false, fmt.Sprintf("Header '%s' found in response.", ha.Name)
+		}
+		return true, ""
+	}
+	if len(values) == 0 {
+		return false, fmt.Sprintf("Header '%s' not found in response.", ha.Name)
+	}
+	expected := fmt.Sprint(ha.Value)
+	for _, v := range values {
+		switch ha.Operator {
+		case "equals":
+			if v == expected {
+				return true, ""
+			}
+		case "regex":
+			if re, err := compileRegexp(expected); err == nil && re.MatchString(v) {
+				return true, ""
+			}
+		}
+	}
+	return false, fmt.Sprintf("Header assertion failed. Expected '%s' %s '%s', got '%s'.", ha.Name, ha.Operator, expected, strings.Join(values, ", "))
+}
+
+// checkHeaders evaluates every header assertion of the Check against the response headers
+func checkHeaders(c Check, header http.Header) (bool, string) {
+	for _, ha := range c.HeaderAssertions {
+		if ok, msg := ha.Evaluate(header); !ok {
+			return false, msg
+		}
+	}
+	return true, ""
+}
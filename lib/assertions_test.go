@@ -0,0 +1,203 @@
+package lib
+
+import (
+	"net/http"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestStatusCodesUnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		yaml     string
+		expected string
+		matches  []int
+		rejects  []int
+	}{
+		{"200", "200", []int{200}, []int{201}},
+		{"2xx", "2xx", []int{200, 299}, []int{199, 300}},
+		{"200-204", "200-204", []int{200, 204}, []int{205}},
+		{"[200, 3xx, 401 - 403]", "200, 3xx, 401-403", []int{200, 301, 402}, []int{201, 400, 404}},
+	}
+	for _, tt := range tests {
+		var sc StatusCodes
+		if err := yaml.Unmarshal([]byte(tt.yaml), &sc); err != nil {
+			t.Errorf("Unmarshal(%q) returned error: %s", tt.yaml, err)
+			continue
+		}
+		if sc.String() != tt.expected {
+			t.Errorf("Unmarshal(%q).String() = %q, want %q", tt.yaml, sc.String(), tt.expected)
+		}
+		for _, status := range tt.matches {
+			if !sc.Matches(status) {
+				t.Errorf("%s doesn't match %d", tt.yaml, status)
+			}
+		}
+		for _, status := range tt.rejects {
+			if sc.Matches(status) {
+				t.Errorf("%s matches %d", tt.yaml, status)
+			}
+		}
+	}
+}
+
+func TestStatusCodesUnmarshalYAMLInvalid(t *testing.T) {
+	for _, s := range []string{"600", "2x", "299-200", "ok"} {
+		var sc StatusCodes
+		if err := yaml.Unmarshal([]byte(s), &sc); err == nil {
+			t.Errorf("Unmarshal(%q) returned no error", s)
+		}
+	}
+}
+
+func TestCheckBody(t *testing.T) {
+	body := `<html><body>version 1.4.2, build abc123</body></html>`
+	tests := []struct {
+		name    string
+		check   Check
+		ok      bool
+		message string
+	}{
+		{"body_match", Check{BodyMatch: StringList{"version", "build"}}, true, ""},
+		{"missing body_match", Check{BodyMatch: StringList{"maintenance"}}, false, "String 'maintenance' not found in body."},
+		{"body_not_contains", Check{BodyNotContains: StringList{"error"}}, true, ""},
+		{"found body_not_contains", Check{BodyNotContains: StringList{"build"}}, false, "String 'build' found in body."},
+		{"body_regex without groups", Check{BodyRegex: StringList{`version \d+`}}, true, ""},
+		{"body_regex captures", Check{BodyRegex: StringList{`version (?P<version>[\d.]+), build (\w+)`}}, true, "Captured version=1.4.2, 2=abc123."},
+		{"unmatched body_regex", Check{BodyRegex: StringList{`release \d+`}}, false, `Pattern 'release \d+' not matched in body.`},
+	}
+	for _, tt := range tests {
+		ok, message := checkBody(tt.check, body)
+		if ok != tt.ok || message != tt.message {
+			t.Errorf("checkBody() with %s = %v, %q, want %v, %q", tt.name, ok, message, tt.ok, tt.message)
+		}
+	}
+}
+
+func TestValidateAssertionsBodyRegex(t *testing.T) {
+	if err := validateAssertions(Check{BodyRegex: StringList{`(unclosed`}}); err == nil {
+		t.Error("validateAssertions() with an invalid body_regex returned no error")
+	}
+}
+
+func TestJSONAssertionEvaluate(t *testing.T) {
+	body := `{"status":"ok","healthy":true,"uptime":3600,"deps":["db","cache"],"error":null,"version":"1.4.2"}`
+	tests := []struct {
+		path     string
+		operator string
+		value    interface{}
+		ok       bool
+	}{
+		{"status", "eq", "ok", true},
+		{"status", "eq", "down", false},
+		{"healthy", "eq", true, true},
+		{"uptime", "eq", 3600, true},
+		{"error", "eq", nil, true},
+		{"status", "ne", "down", true},
+		{"status", "ne", "ok", false},
+		{"uptime", "gt", 60, true},
+		{"uptime", "lt", 60, false},
+		{"uptime", "lt", 7200.5, true},
+		{"status", "gt", 0, false},
+		{"deps", "contains", "db", true},
+		{"deps", "contains", "queue", false},
+		{"version", "contains", "1.4", true},
+		{"status", "exists", nil, true},
+		{"missing", "exists", nil, false},
+		{"missing", "exists", false, true},
+		{"status", "exists", false, false},
+		{"missing", "eq", "ok", false},
+	}
+	for _, tt := range tests {
+		ja := JSONAssertion{Path: tt.path, Operator: tt.operator, Value: tt.value}
+		if ok, message := ja.Evaluate(body); ok != tt.ok {
+			t.Errorf("Evaluate() of %s %s %v = %v (%s), want %v", tt.path, tt.operator, tt.value, ok, message, tt.ok)
+		}
+	}
+}
+
+func TestJSONAssertionValidate(t *testing.T) {
+	tests := []struct {
+		assertion JSONAssertion
+		valid     bool
+	}{
+		{JSONAssertion{Path: "status", Operator: "eq", Value: "ok"}, true},
+		{JSONAssertion{Operator: "eq", Value: "ok"}, false},
+		{JSONAssertion{Path: "status", Operator: "matches", Value: "ok"}, false},
+		{JSONAssertion{Path: "uptime", Operator: "gt", Value: "60"}, false},
+		{JSONAssertion{Path: "deps", Operator: "contains"}, false},
+		{JSONAssertion{Path: "status", Operator: "exists", Value: "yes"}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.assertion.Validate(); (err == nil) != tt.valid {
+			t.Errorf("Validate() of %+v returned %v, want valid %v", tt.assertion, err, tt.valid)
+		}
+	}
+}
+
+func TestCheckBodyInvalidJSON(t *testing.T) {
+	c := Check{JSONAssertions: []JSONAssertion{{Path: "status", Operator: "exists"}}}
+	if ok, message := checkBody(c, "<html>"); ok || message != "Body is not valid JSON." {
+		t.Errorf("checkBody() of HTML with json_assertions = %v, %q, want a failure", ok, message)
+	}
+}
+
+func TestHeaderAssertionEvaluate(t *testing.T) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	header.Add("Cache-Control", "no-cache")
+	header.Add("Cache-Control", "max-age=0")
+	tests := []struct {
+		name     string
+		operator string
+		value    interface{}
+		ok       bool
+	}{
+		{"content-type", "exists", nil, true},
+		{"X-Powered-By", "exists", nil, false},
+		{"X-Powered-By", "exists", false, true},
+		{"Content-Type", "exists", false, false},
+		{"Content-Type", "equals", "application/json; charset=utf-8", true},
+		{"Content-Type", "equals", "application/json", false},
+		{"Cache-Control", "equals", "max-age=0", true},
+		{"Content-Type", "regex", "^application/json", true},
+		{"Content-Type", "regex", "^text/", false},
+		{"X-Powered-By", "equals", "Express", false},
+	}
+	for _, tt := range tests {
+		ha := HeaderAssertion{Name: tt.name, Operator: tt.operator, Value: tt.value}
+		if ok, message := ha.Evaluate(header); ok != tt.ok {
+			t.Errorf("Evaluate() of %s %s %v = %v (%s), want %v", tt.name, tt.operator, tt.value, ok, message, tt.ok)
+		}
+	}
+}
+
+func TestHeaderAssertionValidate(t *testing.T) {
+	tests := []struct {
+		assertion HeaderAssertion
+		valid     bool
+	}{
+		{HeaderAssertion{Name: "Content-Type", Operator: "equals", Value: "text/html"}, true},
+		{HeaderAssertion{Operator: "exists"}, false},
+		{HeaderAssertion{Name: "Content-Type", Operator: "contains", Value: "html"}, false},
+		{HeaderAssertion{Name: "Content-Type", Operator: "equals"}, false},
+		{HeaderAssertion{Name: "Content-Type", Operator: "regex", Value: "(unclosed"}, false},
+		{HeaderAssertion{Name: "Content-Type", Operator: "exists", Value: "yes"}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.assertion.Validate(); (err == nil) != tt.valid {
+			t.Errorf("Validate() of %+v returned %v, want valid %v", tt.assertion, err, tt.valid)
+		}
+	}
+}
+
+func TestCheckHeaders(t *testing.T) {
+	header := http.Header{"Server": []string{"nginx"}}
+	c := Check{HeaderAssertions: []HeaderAssertion{
+		{Name: "Server", Operator: "equals", Value: "nginx"},
+		{Name: "Strict-Transport-Security", Operator: "exists"},
+	}}
+	if ok, message := checkHeaders(c, header); ok || message != "Header 'Strict-Transport-Security' not found in response." {
+		t.Errorf("checkHeaders() = %v, %q, want the first failing assertion", ok, message)
+	}
+}
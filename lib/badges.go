@@ -0,0 +1,258 @@
+package lib
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KixPanganiban/bantay/log"
+)
+
+// badgeUptimeTTL is how long a computed uptime is served before it is computed again, so that
+// embedded badges don't read the whole history on every view
+const badgeUptimeTTL = 5 * time.Minute
+
+// Badge colors, matching those of the Slack alerts
+const (
+	badgeGreen  = "#36a64f"
+	badgeYellow = "#daa038"
+	badgeRed    = "#bd2f2f"
+	badgeGrey   = "#9f9f9f"
+	badgeBlue   = "#007ec6"
+)
+
+// Badges serves SVG badges with the status, 30 day uptime and latency of every check that isn't
+// Internal
+type Badges struct {
+	Checks []Check
+	Store  *IncidentStore
+	// History is used for the uptime badge, which shows as unknown when it is nil
+	History *HistoryStore
+	mu      sync.Mutex
+	uptimes map[string]cachedUptime
+	// computing has a channel for every check whose uptime is being computed, closed once it is
+	computing map[string]chan struct{}
+}
+
+// cachedUptime is the uptime of a check, and until when to serve it
+type cachedUptime struct {
+	value   string
+	color   string
+	expires time.Time
+}
+
+// NewBadges returns Badges for the given checks
+func NewBadges(cs []Check, store *IncidentStore, history *HistoryStore) *Badges {
+	return &Badges{
+		Checks:    cs,
+		Store:     store,
+		History:   history,
+		uptimes:   make(map[string]cachedUptime),
+		computing: make(map[string]chan struct{}),
+	}
+}
+
+// Register adds the badges to the mux:
+//
+//	GET /badge/<name>/status.svg       up, degraded, down or pending
+//	GET /badge/<name>/uptime-30d.svg   uptime percent over the last 30 days
+//	GET /badge/<name>/latency.svg      latency of the last result
+//
+// Every badge takes an optional ?label= to replace the text on its left.
+func (b *Badges) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/badge/", b.serve)
+}
+
+// serve serves the badge named in the path
+func (b *Badges) serve(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/badge/")
+	i := strings.LastIndex(path, "/")
+	if i < 0 {
+		http.NotFound(w, r)
+		return
+	}
+	name, badge := path[:i], path[i+1:]
+	var c Check
+	found := false
+	for _, check := range b.Checks {
+		if check.Name == name && !check.Internal {
+			c, found = check, true
+			break
+		}
+	}
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+	var label, value, color string
+	switch badge {
+	case "status.svg":
+		{
+			label = c.Name
+			value, color = b.status(c)
+		}
+	case "uptime-30d.svg":
+		{
+			label = "uptime 30d"
+			value, color = b.uptime(c)
+		}
+	case "latency.svg":
+		{
+			label = "latency"
+			value, color = b.latency(c)
+		}
+	default:
+		{
+			http.NotFound(w, r)
+			return
+		}
+	}
+	if l := r.URL.Query().Get("label"); len(l) > 0 {
+		label = l
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	// Keep image proxies, like the one GitHub puts in front of READMEs, from serving stale badges
+	w.Header().Set("Cache-Control", "no-cache, max-age=0")
+	fmt.Fprint(w, renderBadge(label, value, color))
+}
+
+// status returns the current state of the Check and its color
+func (b *Badges) status(c Check) (string, string) {
+	state, ok := b.Store.State(c.Name)
+	if !ok {
+		return "pending", badgeGrey
+	}
+	switch state.State {
+	case "down":
+		{
+			return "down", badgeRed
+		}
+	case "degraded":
+		{
+			return "degraded", badgeYellow
+		}
+	}
+	return "up", badgeGreen
+}
+
+// uptime returns the uptime of the Check over the last 30 days and its color, computing it again
+// once the cached one expired. Requests that miss the cache while it is being computed wait for
+// that computation rather than start their own.
+func (b *Badges) uptime(c Check) (string, string) {
+	if b.History == nil {
+		return "unknown", badgeGrey
+	}
+	b.mu.Lock()
+	cached, ok := b.uptimes[c.Name]
+	if ok && time.Now().Before(cached.expires) {
+		b.mu.Unlock()
+		return cached.value, cached.color
+	}
+	if done, ok := b.computing[c.Name]; ok {
+		b.mu.Unlock()
+		<-done
+		b.mu.Lock()
+		cached = b.uptimes[c.Name]
+		b.mu.Unlock()
+		return cached.value, cached.color
+	}
+	done := make(chan struct{})
+	b.computing[c.Name] = done
+	b.mu.Unlock()
+	// Compute it without holding the lock, so that reading the history doesn't hold up other badges
+	cached = b.computeUptime(c)
+	b.mu.Lock()
+	b.uptimes[c.Name] = cached
+	delete(b.computing, c.Name)
+	b.mu.Unlock()
+	close(done)
+	return cached.value, cached.color
+}
+
+// computeUptime computes the uptime of the Check over the last 30 days from its daily summaries,
+// rather than reading every one of its results
+func (b *Badges) computeUptime(c Check) cachedUptime {
+	now := time.Now()
+	cached := cachedUptime{value: "unknown", color: badgeGrey, expires: now.Add(badgeUptimeTTL)}
+	days, err := DailyReports(b.History, c.Name, 30, now)
+	if err != nil {
+		log.Warnf("[%s] Unable to compute uptime badge: %s", c.Name, err.Error())
+		return cached
+	}
+	var monitored, downtime time.Duration
+	for _, day := range days {
+		monitored += day.Monitored
+		downtime += day.Downtime
+	}
+	if monitored <= 0 {
+		return cached
+	}
+	uptime := 100 * float64(monitored-downtime) / float64(monitored)
+	cached.value = fmt.Sprintf("%.2f%%", uptime)
+	switch {
+	case uptime < 99:
+		{
+			cached.color = badgeRed
+		}
+	case uptime < 99.9:
+		{
+			cached.color = badgeYellow
+		}
+	default:
+		{
+			cached.color = badgeGreen
+		}
+	}
+	return cached
+}
+
+// latency returns the latency of the last result of the Check, colored by its latency thresholds
+func (b *Badges) latency(c Check) (string, string) {
+	state, ok := b.Store.State(c.Name)
+	if !ok || !state.LastResult.Success {
+		return "n/a", badgeGrey
+	}
+	latency := state.LastResult.Latency
+	value := latency.Round(time.Millisecond).String()
+	switch {
+	case c.MaxLatency > 0 && latency > c.MaxLatency:
+		{
+			return value, badgeRed
+		}
+	case c.WarnLatency > 0 && latency > c.WarnLatency:
+		{
+			return value, badgeYellow
+		}
+	case c.MaxLatency > 0 || c.WarnLatency > 0:
+		{
+			return value, badgeGreen
+		}
+	}
+	return value, badgeBlue
+}
+
+// badgeTextWidth estimates the width of text in 11px Verdana, which badges are rendered in
+func badgeTextWidth(s string) int {
+	return len([]rune(s))*7 + 10
+}
+
+// renderBadge renders a flat badge with the label on a grey left half and the value on a right
+// half of the given color
+func renderBadge(label string, value string, color string) string {
+	lw, vw := badgeTextWidth(label), badgeTextWidth(value)
+	w := lw + vw
+	label, value = html.EscapeString(label), html.EscapeString(value)
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[4]s: %[5]s">`+
+		`<title>%[4]s: %[5]s</title>`+
+		`<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`+
+		`<clipPath id="r"><rect width="%[1]d" height="20" rx="3" fill="#fff"/></clipPath>`+
+		`<g clip-path="url(#r)"><rect width="%[2]d" height="20" fill="#555"/><rect x="%[2]d" width="%[3]d" height="20" fill="%[6]s"/><rect width="%[1]d" height="20" fill="url(#s)"/></g>`+
+		`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`+
+		`<text x="%[7]d" y="15" fill="#010101" fill-opacity=".3">%[4]s</text><text x="%[7]d" y="14">%[4]s</text>`+
+		`<text x="%[8]d" y="15" fill="#010101" fill-opacity=".3">%[5]s</text><text x="%[8]d" y="14">%[5]s</text>`+
+		`</g></svg>`,
+		w, lw, vw, label, value, color, lw/2, lw+vw/2)
+}
@@ -0,0 +1,98 @@
+package lib
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// getBadge requests the badge at the target, returning the status code and SVG
+func getBadge(handler http.Handler, target string) (int, string) {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+	return w.Code, w.Body.String()
+}
+
+func TestBadges(t *testing.T) {
+	hs, dir := newTestHistoryStore(t)
+	defer os.RemoveAll(dir)
+	hs.results = []HistoryRecord{{Time: time.Now().Add(-time.Hour), Result: CheckResult{Name: "api", Success: true}}}
+	hs.Flush()
+	checks := []Check{
+		{Name: "api", WarnLatency: 100 * time.Millisecond},
+		{Name: "new"},
+		{Name: "db", Internal: true},
+	}
+	store := NewIncidentStore()
+	store.Track(checks[0], CheckResult{Name: "api", Success: true, Latency: 250 * time.Millisecond})
+	store.Track(checks[2], CheckResult{Name: "db", Success: false})
+	mux := http.NewServeMux()
+	NewBadges(checks, store, hs).Register(mux)
+
+	tests := []struct {
+		target string
+		label  string
+		value  string
+		color  string
+	}{
+		{"/badge/api/status.svg", "api", "up", badgeGreen},
+		{"/badge/api/status.svg?label=API", "API", "up", badgeGreen},
+		{"/badge/api/latency.svg", "latency", "250ms", badgeYellow},
+		{"/badge/api/uptime-30d.svg", "uptime 30d", "100.00%", badgeGreen},
+		{"/badge/new/status.svg", "new", "pending", badgeGrey},
+		{"/badge/new/latency.svg", "latency", "n/a", badgeGrey},
+		{"/badge/new/uptime-30d.svg", "uptime 30d", "unknown", badgeGrey},
+	}
+	for _, tt := range tests {
+		code, svg := getBadge(mux, tt.target)
+		if code != http.StatusOK {
+			t.Errorf("GET %s returned %d, want 200", tt.target, code)
+			continue
+		}
+		if !strings.Contains(svg, "aria-label=\""+tt.label+": "+tt.value+"\"") || !strings.Contains(svg, tt.color) {
+			t.Errorf("GET %s = %s, want %s: %s in %s", tt.target, svg, tt.label, tt.value, tt.color)
+		}
+	}
+	for _, target := range []string{"/badge/db/status.svg", "/badge/db/uptime-30d.svg", "/badge/missing/status.svg", "/badge/api/size.svg", "/badge/api"} {
+		if code, _ := getBadge(mux, target); code != http.StatusNotFound {
+			t.Errorf("GET %s returned %d, want 404", target, code)
+		}
+	}
+}
+
+func TestBadgesUptimeSharesComputation(t *testing.T) {
+	hs, dir := newTestHistoryStore(t)
+	defer os.RemoveAll(dir)
+	hs.results = []HistoryRecord{{Time: time.Now().Add(-time.Hour), Result: CheckResult{Name: "api", Success: true}}}
+	hs.Flush()
+	b := NewBadges([]Check{{Name: "api"}}, NewIncidentStore(), hs)
+	var wg sync.WaitGroup
+	values := make([]string, 10)
+	for i := range values {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			values[i], _ = b.uptime(b.Checks[0])
+		}(i)
+	}
+	wg.Wait()
+	for _, value := range values {
+		if value != "100.00%" {
+			t.Errorf("uptime() = %s, want 100.00%% for every concurrent request", value)
+		}
+	}
+	if len(b.computing) != 0 {
+		t.Errorf("computing = %v after every request returned, want none", b.computing)
+	}
+}
+
+func TestRenderBadgeEscapes(t *testing.T) {
+	svg := renderBadge("<script>", "a&b", badgeBlue)
+	if strings.Contains(svg, "<script>") || !strings.Contains(svg, "&lt;script&gt;") || !strings.Contains(svg, "a&amp;b") {
+		t.Errorf("renderBadge() = %s, want the label and value escaped", svg)
+	}
+}
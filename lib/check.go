@@ -1,71 +1,261 @@
 package lib
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/KixPanganiban/bantay/log"
-	"github.com/KixPanganiban/bantay/version"
-
-	"github.com/imroc/req"
 )
 
 // Check is a set of parameters matched against to see if a service is up
 type Check struct {
-	Name        string `yaml:"name"`
-	URL         string `yaml:"url"`
-	ValidStatus int    `yaml:"valid_status"`
-	BodyMatch   string `yaml:"body_match"`
+	Name        string      `yaml:"name"`
+	Type        string      `yaml:"type"`
+	URL         string      `yaml:"url"`
+	ValidStatus StatusCodes `yaml:"valid_status"`
+	// Response header and body assertions for http checks
+	HeaderAssertions []HeaderAssertion `yaml:"header_assertions"`
+	BodyMatch        StringList        `yaml:"body_match"`
+	BodyRegex        StringList        `yaml:"body_regex"`
+	BodyNotContains  StringList        `yaml:"body_not_contains"`
+	JSONAssertions   []JSONAssertion   `yaml:"json_assertions"`
+	// HTTP request customization for http checks
+	Method      string            `yaml:"method"`
+	Headers     map[string]string `yaml:"headers"`
+	QueryParams map[string]string `yaml:"query_params"`
+	Body        string            `yaml:"body"`
+	BodyFile    string            `yaml:"body_file"`
+	BasicAuth   *BasicAuth        `yaml:"basic_auth"`
+	BearerToken string            `yaml:"bearer_token"`
+	// FreshConnection opens a new connection for every request instead of reusing pooled ones,
+	// so that latency includes connecting
+	FreshConnection bool `yaml:"fresh_connection"`
+	// TCP connection and payload for tcp and tls checks
+	Address    string `yaml:"address"`
+	Send       string `yaml:"send"`
+	Expect     string `yaml:"expect"`
+	ServerName string `yaml:"server_name"`
+	// Certificate expiry thresholds, in days, for tls checks and https URLs
+	CertExpiryDays  int `yaml:"cert_expiry_days"`
+	CertWarningDays int `yaml:"cert_warning_days"`
+	// DNS query and expected answers for dns checks
+	Record          string   `yaml:"record"`
+	RecordType      string   `yaml:"record_type"`
+	Resolver        string   `yaml:"resolver"`
+	ExpectedAnswers []string `yaml:"expected_answers"`
+	MinAnswers      int      `yaml:"min_answers"`
+	// Latency thresholds above which a check fails or is degraded
+	MaxLatency  time.Duration `yaml:"max_latency"`
+	WarnLatency time.Duration `yaml:"warn_latency"`
+	// Timeout bounds each attempt of the check, defaulting to the server timeout
+	Timeout time.Duration `yaml:"timeout"`
+	// Retries is the number of times a failed check is re-run before it is reported as failed,
+	// waiting RetryInterval, multiplied by RetryBackoff after every retry, in between
+	Retries       int           `yaml:"retries"`
+	RetryInterval time.Duration `yaml:"retry_interval"`
+	RetryBackoff  float64       `yaml:"retry_backoff"`
+	// AlertAfter and RecoverAfter are the consecutive failures and successes needed to go down
+	// and recover, defaulting to the server settings
+	AlertAfter   int `yaml:"alert_after"`
+	RecoverAfter int `yaml:"recover_after"`
+	// Interval is how often the server runs the check, defaulting to the server poll_interval
+	Interval time.Duration `yaml:"interval"`
+	// Group is the heading the check is listed under on the status page, and Internal hides it
+	// there and from the status API and badges
+	Group    string `yaml:"group"`
+	Internal bool   `yaml:"internal"`
+}
+
+// BasicAuth holds the credentials for HTTP basic authentication
+type BasicAuth struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
 }
 
-//CheckResult contains a fail/success flag and a message
+// DefaultTimeout is the timeout used when neither the Check nor the server set one
+const DefaultTimeout = 10 * time.Second
+
+// DefaultInterval is how often the server runs a check when neither the Check nor the server set it
+const DefaultInterval = 60 * time.Second
+
+// DefaultRetryInterval is the wait before the first retry of a failed check when the Check doesn't set one
+const DefaultRetryInterval = 1 * time.Second
+
+// FailureReason categorizes why a check failed
+type FailureReason string
+
+// Failure reasons set on failed CheckResults
+const (
+	ReasonTimeout     FailureReason = "timeout"
+	ReasonError       FailureReason = "error"
+	ReasonStatus      FailureReason = "status"
+	ReasonAssertion   FailureReason = "assertion"
+	ReasonLatency     FailureReason = "latency"
+	ReasonCertificate FailureReason = "certificate"
+)
+
+// CheckResult contains a fail/success flag and a message
 type CheckResult struct {
-	Name    string
-	Success bool
-	Message string
-	Latency time.Duration
-}
-
-// RunCheck performs the HTTP request necessary to verify if the given Check is up
-func RunCheck(c Check, resChan chan<- CheckResult) {
-	r := req.New()
-	r.SetFlags(req.Lcost)
-	res, err := r.Get(
-		c.URL,
-		req.Header{"User-Agent": fmt.Sprintf("Bantay %s", version.Version)},
-	)
+	Name    string `json:"name"`
+	Success bool   `json:"success"`
+	// Degraded is set on successful checks that exceeded a warning threshold
+	Degraded bool `json:"degraded"`
+	// Reason categorizes why a check failed
+	Reason  FailureReason `json:"reason,omitempty"`
+	Message string        `json:"message"`
+	Latency time.Duration `json:"latency"`
+	// Attempts is the number of times the check was run to produce this result
+	Attempts int `json:"attempts"`
+	// CertExpiry is the earliest expiry in the peer certificate chain, if any
+	CertExpiry time.Time `json:"cert_expiry"`
+	// Timing breaks Latency down into phases, for checks that measure them
+	Timing *Timing `json:"timing,omitempty"`
+}
+
+// Timing is the time spent in each phase of an HTTP check. Phases skipped because a pooled
+// connection was reused are zero.
+type Timing struct {
+	DNSLookup    time.Duration `json:"dns_lookup"`
+	TCPConnect   time.Duration `json:"tcp_connect"`
+	TLSHandshake time.Duration `json:"tls_handshake"`
+	// FirstByte is the time from starting the request to receiving the first byte of the response
+	FirstByte time.Duration `json:"first_byte"`
+	// Transfer is the time from the first byte of the response to the end of its body
+	Transfer time.Duration `json:"transfer"`
+}
+
+// Status returns the state of the check as one of up, degraded or down
+func (c CheckResult) Status() string {
+	switch {
+	case !c.Success:
+		return "down"
+	case c.Degraded:
+		return "degraded"
+	}
+	return "up"
+}
+
+// CertDaysRemaining returns the number of whole days until CertExpiry
+func (c CheckResult) CertDaysRemaining() int {
+	return int(time.Until(c.CertExpiry).Hours() / 24)
+}
+
+// RunCheck dispatches the given Check to the Checker registered for its type, retrying failed
+// attempts as configured on the Check
+func RunCheck(ctx context.Context, c Check, resChan chan<- CheckResult) {
+	resChan <- runCheck(ctx, c)
+}
+
+// runCheck runs the Check until it succeeds or runs out of retries
+func runCheck(ctx context.Context, c Check) CheckResult {
+	checker, err := GetChecker(c.Type)
 	if err != nil {
-		log.Warnf("Request failed: %s", err.Error())
-		resChan <- CheckResult{Name: c.Name, Success: false, Message: err.Error(), Latency: 0 * time.Second}
-		return
-	}
-	// Perform check on StatusCode
-	response := res.Response()
-	responseStatus := response.StatusCode
-	if responseStatus != c.ValidStatus {
-		errMsg := fmt.Sprintf("Status mismatch. Expected %d, got %d.", c.ValidStatus, responseStatus)
-		resChan <- CheckResult{Name: c.Name, Success: false, Message: errMsg, Latency: res.Cost()}
-		return
-	}
-	// Perform check on Body
-	if len(c.BodyMatch) > 0 {
-		responseBuffer := new(bytes.Buffer)
-		responseBuffer.ReadFrom(response.Body)
-		responseText := responseBuffer.String()
-		if !strings.Contains(responseText, c.BodyMatch) {
-			errMsg := fmt.Sprintf("String '%s' not found in body.", c.BodyMatch)
-			resChan <- CheckResult{Name: c.Name, Success: false, Message: errMsg, Latency: res.Cost()}
-			return
+		return CheckResult{Name: c.Name, Success: false, Reason: ReasonError, Message: err.Error(), Latency: 0 * time.Second, Attempts: 1}
+	}
+	retryInterval := c.RetryInterval
+	if retryInterval <= 0 {
+		retryInterval = DefaultRetryInterval
+	}
+	var res CheckResult
+	for attempt := 1; ; attempt++ {
+		res = runAttempt(ctx, checker, c)
+		res.Attempts = attempt
+		if res.Success || attempt > c.Retries {
+			break
+		}
+		log.Debugf("[%s] Attempt %d failed, retrying in %s. Reason: %s", c.Name, attempt, retryInterval, res.Message)
+		select {
+		case <-ctx.Done():
+			return res
+		case <-time.After(retryInterval):
 		}
+		if c.RetryBackoff > 1 {
+			retryInterval = time.Duration(float64(retryInterval) * c.RetryBackoff)
+		}
+	}
+	return res
+}
+
+// runAttempt runs the Check once, cancelling it once the Check timeout elapses
+func runAttempt(ctx context.Context, checker Checker, c Check) CheckResult {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return checkLatency(c, checker.Check(attemptCtx, c))
+}
+
+// validateRetries ensures the retry settings of the Check are sane
+func validateRetries(c Check) error {
+	if c.Retries < 0 || c.RetryInterval < 0 {
+		return errors.New("retries and retry_interval can't be negative")
+	}
+	if err := validateDuration("retry_interval", c.RetryInterval, time.Millisecond); err != nil {
+		return err
+	}
+	if c.RetryBackoff != 0 && c.RetryBackoff < 1 {
+		return fmt.Errorf("retry_backoff (%g) must not be less than 1", c.RetryBackoff)
+	}
+	return nil
+}
+
+// validateDuration ensures a duration set in checks.yml is at least min. YAML reads a bare number
+// like 30 as nanoseconds, so this catches durations given without a unit.
+func validateDuration(name string, d time.Duration, min time.Duration) error {
+	if d > 0 && d < min {
+		return fmt.Errorf("%s (%s) must be at least %s, set it with a unit like 5s", name, d, min)
 	}
-	resChan <- CheckResult{Name: c.Name, Success: true, Latency: res.Cost()}
-	return
+	return nil
+}
+
+// validateTimeout ensures the timeout of the Check is set with a unit
+func validateTimeout(c Check) error {
+	return validateDuration("timeout", c.Timeout, time.Millisecond)
 }
 
-// RunChecks calls RunCheck for every Check provided in slice cs and returns counts for failed, successful, total
-func RunChecks(cs *[]Check, r *[]Reporter, downCounter map[string]int) (int, int, int) {
+// validateLatency ensures the latency thresholds of the Check are sane
+func validateLatency(c Check) error {
+	if c.MaxLatency < 0 || c.WarnLatency < 0 {
+		return errors.New("max_latency and warn_latency can't be negative")
+	}
+	if err := validateDuration("max_latency", c.MaxLatency, time.Millisecond); err != nil {
+		return err
+	}
+	if err := validateDuration("warn_latency", c.WarnLatency, time.Millisecond); err != nil {
+		return err
+	}
+	if c.MaxLatency > 0 && c.WarnLatency > c.MaxLatency {
+		return fmt.Errorf("warn_latency (%s) must not be greater than max_latency (%s)", c.WarnLatency, c.MaxLatency)
+	}
+	return nil
+}
+
+// checkLatency fails or degrades a successful CheckResult that exceeded the latency thresholds of the Check
+func checkLatency(c Check, res CheckResult) CheckResult {
+	if !res.Success {
+		return res
+	}
+	if c.MaxLatency > 0 && res.Latency > c.MaxLatency {
+		res.Success = false
+		res.Degraded = false
+		res.Reason = ReasonLatency
+		res.Message = fmt.Sprintf("Latency %s exceeded max_latency %s.", res.Latency, c.MaxLatency)
+	} else if c.WarnLatency > 0 && res.Latency > c.WarnLatency {
+		res.Degraded = true
+		res.Message = strings.TrimSpace(fmt.Sprintf("%s Latency %s exceeded warn_latency %s.", res.Message, res.Latency, c.WarnLatency))
+	}
+	return res
+}
+
+// RunChecks runs every Check provided in slice cs on the WorkerPool, tracks each result with the
+// IncidentStore before passing the resulting Event to every Reporter, and returns counts for failed,
+// successful, total
+func RunChecks(ctx context.Context, pool *WorkerPool, cs *[]Check, r *[]Reporter, store *IncidentStore) (int, int, int) {
 	var (
 		failed     int
 		successful int
@@ -74,24 +264,39 @@ func RunChecks(cs *[]Check, r *[]Reporter, downCounter map[string]int) (int, int
 	failed, successful = 0, 0
 	total = len(*cs)
 
+	checks := make(map[string]Check, total)
 	resChan := make(chan CheckResult, total)
 	for _, c := range *cs {
-		go RunCheck(c, resChan)
+		checks[c.Name] = c
+		go func(c Check) {
+			res, err := pool.Run(ctx, c)
+			if err != nil {
+				res = CheckResult{Name: c.Name, Success: false, Reason: ReasonError, Message: err.Error(), Latency: 0 * time.Second}
+			}
+			resChan <- res
+		}(c)
 	}
 	func() {
 		for i := 0; i < total; i++ {
 			res := <-resChan
-			for _, reporter := range *r {
-				reporter.Report(res, &downCounter)
-			}
+			reportResult(ctx, checks[res.Name], res, *r, store)
 			if res.Success == true {
 				successful++
-				downCounter[res.Name] = 0
 			} else {
 				failed++
-				downCounter[res.Name]++
 			}
 		}
 	}()
 	return failed, successful, total
 }
+
+// reportResult tracks the result of the Check with the IncidentStore and passes the resulting
+// Event to every Reporter
+func reportResult(ctx context.Context, c Check, res CheckResult, r []Reporter, store *IncidentStore) {
+	ev := store.Track(c, res)
+	for _, reporter := range r {
+		if err := reporter.Report(ctx, ev); err != nil {
+			log.Warnf("[%s] Unable to report check result: %s", res.Name, err.Error())
+		}
+	}
+}
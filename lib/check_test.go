@@ -0,0 +1,138 @@
+package lib
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckLatency(t *testing.T) {
+	c := Check{Name: "a", WarnLatency: 200 * time.Millisecond, MaxLatency: time.Second}
+	tests := []struct {
+		name    string
+		res     CheckResult
+		status  string
+		reason  FailureReason
+		message string
+	}{
+		{"below warn_latency", CheckResult{Success: true, Latency: 100 * time.Millisecond}, "up", "", ""},
+		{"above warn_latency", CheckResult{Success: true, Latency: 500 * time.Millisecond, Message: "Captured 1=a."}, "degraded", "", "Captured 1=a. Latency 500ms exceeded warn_latency 200ms."},
+		{"above max_latency", CheckResult{Success: true, Latency: 2 * time.Second}, "down", ReasonLatency, "Latency 2s exceeded max_latency 1s."},
+		{"already failed", CheckResult{Success: false, Reason: ReasonStatus, Latency: 2 * time.Second}, "down", ReasonStatus, ""},
+	}
+	for _, tt := range tests {
+		res := checkLatency(c, tt.res)
+		if res.Status() != tt.status || res.Reason != tt.reason || res.Message != tt.message {
+			t.Errorf("checkLatency() %s = %s, %q, %q, want %s, %q, %q", tt.name, res.Status(), res.Reason, res.Message, tt.status, tt.reason, tt.message)
+		}
+	}
+}
+
+func TestValidateLatency(t *testing.T) {
+	if err := validateLatency(Check{WarnLatency: time.Second, MaxLatency: 2 * time.Second}); err != nil {
+		t.Errorf("validateLatency() returned error: %s", err)
+	}
+	if err := validateLatency(Check{WarnLatency: 2 * time.Second, MaxLatency: time.Second}); err == nil {
+		t.Error("validateLatency() with warn_latency above max_latency returned no error")
+	}
+	if err := validateLatency(Check{MaxLatency: -time.Second}); err == nil {
+		t.Error("validateLatency() with negative max_latency returned no error")
+	}
+}
+
+func TestRunCheckTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Hang until the check gives up, or the test ends
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+	c := Check{Name: "hung", Type: "http", URL: srv.URL, Timeout: 50 * time.Millisecond}
+	start := time.Now()
+	res := runCheck(context.Background(), c)
+	if res.Success || res.Reason != ReasonTimeout {
+		t.Errorf("runCheck() = %+v, want a failure with reason %s", res, ReasonTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("runCheck() took %s, want it to give up after the 50ms timeout", elapsed)
+	}
+}
+
+func TestRunCheckRetries(t *testing.T) {
+	failure := CheckResult{Name: "stub", Success: false, Reason: ReasonStatus}
+	success := CheckResult{Name: "stub", Success: true}
+	tests := []struct {
+		name     string
+		retries  int
+		results  []CheckResult
+		success  bool
+		attempts int
+	}{
+		{"no retries", 0, []CheckResult{failure}, false, 1},
+		{"recovers on retry", 3, []CheckResult{failure, success}, true, 2},
+		{"runs out of retries", 2, []CheckResult{failure, failure, failure}, false, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &stubChecker{results: tt.results}
+			RegisterChecker("stub", stub)
+			defer delete(checkers, "stub")
+			c := Check{Name: "stub", Type: "stub", Retries: tt.retries, RetryInterval: time.Millisecond}
+			res := runCheck(context.Background(), c)
+			if res.Success != tt.success || res.Attempts != tt.attempts || stub.runs != tt.attempts {
+				t.Errorf("runCheck() = success %v after %d attempts and %d runs, want %v after %d", res.Success, res.Attempts, stub.runs, tt.success, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestRunCheckRetryBackoff(t *testing.T) {
+	failure := CheckResult{Name: "stub", Success: false, Reason: ReasonStatus}
+	stub := &stubChecker{results: []CheckResult{failure, failure, failure, failure}}
+	RegisterChecker("stub", stub)
+	defer delete(checkers, "stub")
+	c := Check{Name: "stub", Type: "stub", Retries: 3, RetryInterval: 10 * time.Millisecond, RetryBackoff: 2}
+	start := time.Now()
+	runCheck(context.Background(), c)
+	// Retries wait 10ms, 20ms then 40ms
+	if elapsed := time.Since(start); elapsed < 70*time.Millisecond {
+		t.Errorf("runCheck() took %s, want at least 70ms of backoff", elapsed)
+	}
+}
+
+func TestRunCheckRetryCancelled(t *testing.T) {
+	failure := CheckResult{Name: "stub", Success: false, Reason: ReasonStatus}
+	stub := &stubChecker{results: []CheckResult{failure, failure}}
+	RegisterChecker("stub", stub)
+	defer delete(checkers, "stub")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res := runCheck(ctx, Check{Name: "stub", Type: "stub", Retries: 1, RetryInterval: time.Hour})
+	if res.Attempts != 1 || stub.runs != 1 {
+		t.Errorf("runCheck() with a cancelled context made %d attempts, want 1", res.Attempts)
+	}
+}
+
+func TestValidateRetries(t *testing.T) {
+	tests := []struct {
+		name  string
+		check Check
+		valid bool
+	}{
+		{"retries", Check{Retries: 2, RetryInterval: 500 * time.Millisecond, RetryBackoff: 2}, true},
+		{"negative retries", Check{Retries: -1}, false},
+		{"retry_interval without unit", Check{Retries: 2, RetryInterval: 5}, false},
+		{"retry_backoff below 1", Check{Retries: 2, RetryBackoff: 0.5}, false},
+	}
+	for _, tt := range tests {
+		if err := validateRetries(tt.check); (err == nil) != tt.valid {
+			t.Errorf("validateRetries() of %s returned %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultCheckType is the check type used when a Check does not specify one
+const DefaultCheckType = "http"
+
+// Checker probes a service described by a Check and returns the outcome, giving up once the
+// context is done
+type Checker interface {
+	Validate(Check) error
+	Check(context.Context, Check) CheckResult
+}
+
+var checkers = map[string]Checker{}
+
+// RegisterChecker makes a Checker available for checks with the given type
+func RegisterChecker(checkType string, checker Checker) {
+	checkers[checkType] = checker
+}
+
+// GetChecker returns the Checker registered for the given check type
+func GetChecker(checkType string) (Checker, error) {
+	if checkType == "" {
+		checkType = DefaultCheckType
+	}
+	checker, ok := checkers[checkType]
+	if !ok {
+		return nil, fmt.Errorf("unknown check type %s, expected one of: %s", checkType, strings.Join(CheckTypes(), ", "))
+	}
+	return checker, nil
+}
+
+// CheckTypes lists the check types that have a registered Checker
+func CheckTypes() []string {
+	types := make([]string, 0, len(checkers))
+	for t := range checkers {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// errorResult builds a failed CheckResult for an error returned while probing a service,
+// distinguishing timeouts from other errors
+func errorResult(ctx context.Context, c Check, err error, latency time.Duration) CheckResult {
+	if isTimeout(ctx, err) {
+		errMsg := fmt.Sprintf("Timed out: %s", err.Error())
+		return CheckResult{Name: c.Name, Success: false, Reason: ReasonTimeout, Message: errMsg, Latency: latency}
+	}
+	return CheckResult{Name: c.Name, Success: false, Reason: ReasonError, Message: err.Error(), Latency: latency}
+}
+
+// isTimeout returns true if the context deadline passed or err is a network timeout
+func isTimeout(ctx context.Context, err error) bool {
+	if ctx.Err() == context.DeadlineExceeded {
+		return true
+	}
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
@@ -0,0 +1,170 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dnsRecordTypes are the record types a DNS check can query
+var dnsRecordTypes = []string{"A", "AAAA", "CNAME", "MX", "TXT", "SRV"}
+
+// DNSChecker implements Checker by resolving a DNS record
+type DNSChecker struct{}
+
+func init() {
+	RegisterChecker("dns", DNSChecker{})
+}
+
+// Validate ensures the Check has a record to query, of a supported type
+func (dc DNSChecker) Validate(c Check) error {
+	if len(c.Record) == 0 {
+		return errors.New("record is required")
+	}
+	if _, err := dnsRecordType(c); err != nil {
+		return err
+	}
+	if len(c.Resolver) > 0 {
+		if _, _, err := net.SplitHostPort(c.Resolver); err != nil {
+			return fmt.Errorf("invalid resolver %s: %s", c.Resolver, err.Error())
+		}
+	}
+	if c.MinAnswers < 0 {
+		return errors.New("min_answers can't be negative")
+	}
+	return nil
+}
+
+// Check resolves the Check record and matches the answers against the expected values
+func (dc DNSChecker) Check(ctx context.Context, c Check) CheckResult {
+	recordType, _ := dnsRecordType(c)
+	start := time.Now()
+	answers, err := dnsLookup(ctx, dnsResolver(c), recordType, c.Record)
+	latency := time.Since(start)
+	if err != nil {
+		res := errorResult(ctx, c, err, latency)
+		res.Message = fmt.Sprintf("DNS lookup of %s record for %s failed: %s", recordType, c.Record, err.Error())
+		return res
+	}
+	// Perform check on the number of answers
+	minAnswers := c.MinAnswers
+	if minAnswers == 0 {
+		minAnswers = 1
+	}
+	if len(answers) < minAnswers {
+		errMsg := fmt.Sprintf("Answer count mismatch. Expected at least %d, got %d.", minAnswers, len(answers))
+		return CheckResult{Name: c.Name, Success: false, Reason: ReasonAssertion, Message: errMsg, Latency: latency}
+	}
+	// Perform check on the answers
+	for _, expected := range c.ExpectedAnswers {
+		found := false
+		for _, answer := range answers {
+			if dnsAnswerMatches(recordType, answer, expected) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			errMsg := fmt.Sprintf("Answer '%s' not found in %s records: %s.", expected, recordType, strings.Join(answers, ", "))
+			return CheckResult{Name: c.Name, Success: false, Reason: ReasonAssertion, Message: errMsg, Latency: latency}
+		}
+	}
+	return CheckResult{Name: c.Name, Success: true, Latency: latency}
+}
+
+// dnsRecordType returns the upper-cased record type of the Check, defaulting to A
+func dnsRecordType(c Check) (string, error) {
+	if len(c.RecordType) == 0 {
+		return "A", nil
+	}
+	recordType := strings.ToUpper(c.RecordType)
+	for _, t := range dnsRecordTypes {
+		if t == recordType {
+			return recordType, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported record_type %s, expected one of: %s", c.RecordType, strings.Join(dnsRecordTypes, ", "))
+}
+
+// dnsResolver returns a resolver that queries the Check resolver, or the system resolver if unset
+func dnsResolver(c Check) *net.Resolver {
+	if len(c.Resolver) == 0 {
+		return net.DefaultResolver
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			d := net.Dialer{}
+			return d.DialContext(ctx, network, c.Resolver)
+		},
+	}
+}
+
+// dnsLookup resolves the given record and returns the answers as strings
+func dnsLookup(ctx context.Context, r *net.Resolver, recordType string, name string) ([]string, error) {
+	var answers []string
+	switch recordType {
+	case "A", "AAAA":
+		{
+			addrs, err := r.LookupIPAddr(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			for _, addr := range addrs {
+				if (addr.IP.To4() != nil) == (recordType == "A") {
+					answers = append(answers, addr.IP.String())
+				}
+			}
+		}
+	case "CNAME":
+		{
+			cname, err := r.LookupCNAME(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			answers = append(answers, strings.TrimSuffix(cname, "."))
+		}
+	case "MX":
+		{
+			mxs, err := r.LookupMX(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			for _, mx := range mxs {
+				answers = append(answers, strings.TrimSuffix(mx.Host, "."))
+			}
+		}
+	case "TXT":
+		{
+			txts, err := r.LookupTXT(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			answers = append(answers, txts...)
+		}
+	case "SRV":
+		{
+			_, srvs, err := r.LookupSRV(ctx, "", "", name)
+			if err != nil {
+				return nil, err
+			}
+			for _, srv := range srvs {
+				answers = append(answers, net.JoinHostPort(strings.TrimSuffix(srv.Target, "."), strconv.Itoa(int(srv.Port))))
+			}
+		}
+	}
+	return answers, nil
+}
+
+// dnsAnswerMatches compares an answer with an expected value, ignoring case and the trailing dot
+// of fully qualified names for every record type but TXT
+func dnsAnswerMatches(recordType string, answer string, expected string) bool {
+	if recordType == "TXT" {
+		return answer == expected
+	}
+	return strings.EqualFold(answer, strings.Replace(strings.TrimSuffix(expected, "."), ".:", ":", 1))
+}
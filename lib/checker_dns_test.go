@@ -0,0 +1,71 @@
+package lib
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDNSAnswerMatches(t *testing.T) {
+	tests := []struct {
+		recordType string
+		answer     string
+		expected   string
+		matches    bool
+	}{
+		{"A", "93.184.216.34", "93.184.216.34", true},
+		{"A", "93.184.216.34", "93.184.216.35", false},
+		{"CNAME", "example.com", "Example.com.", true},
+		{"MX", "mail.example.com", "mail.example.com", true},
+		{"SRV", "sip.example.com:5060", "sip.example.com.:5060", true},
+		{"TXT", "v=spf1 -all", "v=spf1 -all", true},
+		{"TXT", "v=spf1 -all", "V=SPF1 -all", false},
+	}
+	for _, tt := range tests {
+		if matches := dnsAnswerMatches(tt.recordType, tt.answer, tt.expected); matches != tt.matches {
+			t.Errorf("dnsAnswerMatches(%q, %q, %q) = %v, want %v", tt.recordType, tt.answer, tt.expected, matches, tt.matches)
+		}
+	}
+}
+
+func TestDNSRecordType(t *testing.T) {
+	if recordType, err := dnsRecordType(Check{}); err != nil || recordType != "A" {
+		t.Errorf("dnsRecordType() without record_type = %q, %v, want A", recordType, err)
+	}
+	if recordType, err := dnsRecordType(Check{RecordType: "mx"}); err != nil || recordType != "MX" {
+		t.Errorf("dnsRecordType(mx) = %q, %v, want MX", recordType, err)
+	}
+	if _, err := dnsRecordType(Check{RecordType: "PTR"}); err == nil {
+		t.Error("dnsRecordType(PTR) returned no error")
+	}
+}
+
+func TestDNSCheckerValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		check Check
+		valid bool
+	}{
+		{"record", Check{Record: "example.com"}, true},
+		{"no record", Check{}, false},
+		{"unsupported type", Check{Record: "example.com", RecordType: "PTR"}, false},
+		{"resolver without port", Check{Record: "example.com", Resolver: "1.1.1.1"}, false},
+		{"resolver", Check{Record: "example.com", Resolver: "1.1.1.1:53"}, true},
+		{"negative min_answers", Check{Record: "example.com", MinAnswers: -1}, false},
+	}
+	for _, tt := range tests {
+		if err := (DNSChecker{}).Validate(tt.check); (err == nil) != tt.valid {
+			t.Errorf("Validate() of %s returned %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestDNSCheckerLocalhost(t *testing.T) {
+	c := Check{Name: "dns", Type: "dns", Record: "localhost", ExpectedAnswers: []string{"127.0.0.1"}}
+	if res := (DNSChecker{}).Check(context.Background(), c); !res.Success {
+		t.Errorf("Check() of localhost failed: %s", res.Message)
+	}
+	c.ExpectedAnswers = []string{"10.0.0.1"}
+	if res := (DNSChecker{}).Check(context.Background(), c); res.Success || res.Reason != ReasonAssertion {
+		t.Errorf("Check() of localhost for 10.0.0.1 = %+v, want a failure with reason assertion", res)
+	}
+}
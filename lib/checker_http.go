@@ -0,0 +1,249 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KixPanganiban/bantay/log"
+	"github.com/KixPanganiban/bantay/version"
+
+	"github.com/imroc/req"
+)
+
+// HTTPChecker implements Checker by sending an HTTP request to the Check URL. Requests share a
+// single client, and so its connection pool, unless the Check asks for a fresh connection.
+type HTTPChecker struct {
+	client *http.Client
+}
+
+func init() {
+	RegisterChecker("http", NewHTTPChecker())
+}
+
+// NewHTTPChecker returns an HTTPChecker with its own pooled HTTP client
+func NewHTTPChecker() HTTPChecker {
+	return HTTPChecker{client: &http.Client{Transport: newHTTPTransport(true)}}
+}
+
+// newHTTPTransport returns a transport with the defaults of http.DefaultTransport, which only keeps
+// connections alive for reuse when keepAlive is set
+func newHTTPTransport(keepAlive bool) *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		DisableKeepAlives:     !keepAlive,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// Validate ensures the Check has a URL to request and a usable request body and credentials
+func (hc HTTPChecker) Validate(c Check) error {
+	if len(c.URL) == 0 {
+		return errors.New("url is required")
+	}
+	if len(c.ValidStatus) == 0 {
+		return errors.New("valid_status is required")
+	}
+	if err := validateAssertions(c); err != nil {
+		return err
+	}
+	if len(c.Body) > 0 && len(c.BodyFile) > 0 {
+		return errors.New("only one of body and body_file can be set")
+	}
+	if len(c.BodyFile) > 0 {
+		if _, err := os.Stat(c.BodyFile); err != nil {
+			return fmt.Errorf("can't read body_file: %s", err.Error())
+		}
+	}
+	if c.BasicAuth != nil && len(c.BearerToken) > 0 {
+		return errors.New("only one of basic_auth and bearer_token can be set")
+	}
+	return validateCertExpiry(c)
+}
+
+// Check performs the HTTP request necessary to verify if the given Check is up
+func (hc HTTPChecker) Check(ctx context.Context, c Check) CheckResult {
+	method := strings.ToUpper(c.Method)
+	if len(method) == 0 {
+		method = "GET"
+	}
+	args, err := httpRequestArgs(c)
+	if err != nil {
+		log.Warnf("Unable to build request: %s", err.Error())
+		return CheckResult{Name: c.Name, Success: false, Reason: ReasonError, Message: err.Error(), Latency: 0 * time.Second}
+	}
+	timer := &httpTimer{}
+	args = append(args, httptrace.WithClientTrace(ctx, timer.trace()))
+	client := hc.client
+	if c.FreshConnection || client == nil {
+		transport := newHTTPTransport(false)
+		defer transport.CloseIdleConnections()
+		client = &http.Client{Transport: transport}
+	}
+	r := req.New()
+	r.SetClient(client)
+	r.SetFlags(req.Lcost)
+	timer.start = time.Now()
+	res, err := r.Do(method, c.URL, args...)
+	if err != nil {
+		log.Warnf("Request failed: %s", err.Error())
+		return errorResult(ctx, c, err, 0*time.Second)
+	}
+	// Read the whole body to time its transfer, then close it so the connection can be reused
+	response := res.Response()
+	responseBuffer := new(bytes.Buffer)
+	_, err = responseBuffer.ReadFrom(response.Body)
+	response.Body.Close()
+	timing := timer.timing(time.Now())
+	if err != nil {
+		result := errorResult(ctx, c, err, res.Cost())
+		result.Timing = &timing
+		return result
+	}
+	// Perform check on StatusCode
+	responseStatus := response.StatusCode
+	if !c.ValidStatus.Matches(responseStatus) {
+		expected := c.ValidStatus.String()
+		if len(c.ValidStatus) > 1 {
+			expected = "one of " + expected
+		}
+		errMsg := fmt.Sprintf("Status mismatch. Expected %s, got %d.", expected, responseStatus)
+		return CheckResult{Name: c.Name, Success: false, Reason: ReasonStatus, Message: errMsg, Latency: res.Cost(), Timing: &timing}
+	}
+	// Perform check on Headers
+	if ok, headerMsg := checkHeaders(c, response.Header); !ok {
+		return CheckResult{Name: c.Name, Success: false, Reason: ReasonAssertion, Message: headerMsg, Latency: res.Cost(), Timing: &timing}
+	}
+	// Perform check on Body
+	var message string
+	if hasBodyAssertions(c) {
+		ok, bodyMsg := checkBody(c, responseBuffer.String())
+		if !ok {
+			return CheckResult{Name: c.Name, Success: false, Reason: ReasonAssertion, Message: bodyMsg, Latency: res.Cost(), Timing: &timing}
+		}
+		message = bodyMsg
+	}
+	result := CheckResult{Name: c.Name, Success: true, Message: message, Latency: res.Cost(), Timing: &timing}
+	return checkCertExpiry(c, response.TLS, result)
+}
+
+// httpTimer records when each phase of an HTTP request starts and ends. When the request is
+// redirected, the phases are recorded again for every hop, so they describe the last one.
+type httpTimer struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+}
+
+// trace returns the hooks that record the phases of a request, which may be called concurrently
+func (t *httpTimer) trace() *httptrace.ClientTrace {
+	record := func(at *time.Time) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if at.IsZero() {
+			*at = time.Now()
+		}
+	}
+	return &httptrace.ClientTrace{
+		GetConn:              func(string) { t.hop() },
+		DNSStart:             func(httptrace.DNSStartInfo) { record(&t.dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { record(&t.dnsDone) },
+		ConnectStart:         func(string, string) { record(&t.connectStart) },
+		ConnectDone:          func(string, string, error) { record(&t.connectDone) },
+		TLSHandshakeStart:    func() { record(&t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { record(&t.tlsDone) },
+		GotFirstResponseByte: func() { record(&t.firstByte) },
+	}
+}
+
+// hop starts recording the phases of a new request once the previous one got a response, as
+// when following a redirect
+func (t *httpTimer) hop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.firstByte.IsZero() {
+		return
+	}
+	t.start = time.Now()
+	t.dnsStart, t.dnsDone = time.Time{}, time.Time{}
+	t.connectStart, t.connectDone = time.Time{}, time.Time{}
+	t.tlsStart, t.tlsDone = time.Time{}, time.Time{}
+	t.firstByte = time.Time{}
+}
+
+// timing computes the duration of each phase, given when the body finished transferring
+func (t *httpTimer) timing(done time.Time) Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	between := func(start time.Time, end time.Time) time.Duration {
+		if start.IsZero() || end.IsZero() {
+			return 0
+		}
+		return end.Sub(start)
+	}
+	return Timing{
+		DNSLookup:    between(t.dnsStart, t.dnsDone),
+		TCPConnect:   between(t.connectStart, t.connectDone),
+		TLSHandshake: between(t.tlsStart, t.tlsDone),
+		FirstByte:    between(t.start, t.firstByte),
+		Transfer:     between(t.firstByte, done),
+	}
+}
+
+// httpRequestArgs builds the headers, query parameters and body to send for the given Check
+func httpRequestArgs(c Check) ([]interface{}, error) {
+	header := req.Header{"User-Agent": fmt.Sprintf("Bantay %s", version.Version)}
+	if c.BasicAuth != nil {
+		credentials := base64.StdEncoding.EncodeToString([]byte(c.BasicAuth.Username + ":" + c.BasicAuth.Password))
+		header["Authorization"] = "Basic " + credentials
+	}
+	if len(c.BearerToken) > 0 {
+		header["Authorization"] = "Bearer " + c.BearerToken
+	}
+	for k, v := range c.Headers {
+		header[k] = v
+	}
+	args := []interface{}{header}
+	if len(c.QueryParams) > 0 {
+		queryParam := req.QueryParam{}
+		for k, v := range c.QueryParams {
+			queryParam[k] = v
+		}
+		args = append(args, queryParam)
+	}
+	if len(c.Body) > 0 {
+		args = append(args, []byte(c.Body))
+	} else if len(c.BodyFile) > 0 {
+		body, err := ioutil.ReadFile(c.BodyFile)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, body)
+	}
+	return args, nil
+}
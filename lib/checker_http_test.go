@@ -0,0 +1,209 @@
+package lib
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPCheckerStatus(t *testing.T) {
+	srv := newTestServer(http.StatusOK, "ok")
+	defer srv.Close()
+	tests := []struct {
+		name        string
+		validStatus StatusCodes
+		success     bool
+	}{
+		{"matching status", StatusCodes{{200, 200}}, true},
+		{"mismatched status", StatusCodes{{204, 204}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := NewHTTPChecker().Check(context.Background(), Check{Name: "a", URL: srv.URL, ValidStatus: tt.validStatus})
+			if res.Success != tt.success {
+				t.Errorf("Check() success = %v, want %v: %s", res.Success, tt.success, res.Message)
+			}
+			if !tt.success && res.Reason != ReasonStatus {
+				t.Errorf("Check() reason = %q, want status", res.Reason)
+			}
+		})
+	}
+}
+
+func TestHTTPCheckerRequestError(t *testing.T) {
+	srv := newTestServer(http.StatusOK, "ok")
+	url := srv.URL
+	srv.Close()
+	res := NewHTTPChecker().Check(context.Background(), Check{Name: "a", URL: url, ValidStatus: StatusCodes{{200, 200}}})
+	if res.Success || res.Reason != ReasonError {
+		t.Errorf("Check() of a closed server = %+v, want a failure with reason error", res)
+	}
+}
+
+// echoedRequest is what newEchoServer saw of a request
+type echoedRequest struct {
+	method string
+	header http.Header
+	query  url.Values
+	body   string
+}
+
+// newEchoServer returns a server that records every request it receives on the channel
+func newEchoServer(requests chan<- echoedRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- echoedRequest{method: r.Method, header: r.Header, query: r.URL.Query(), body: string(body)}
+	}))
+}
+
+func TestHTTPCheckerRequest(t *testing.T) {
+	requests := make(chan echoedRequest, 1)
+	srv := newEchoServer(requests)
+	defer srv.Close()
+	bodyFile, err := ioutil.TempFile("", "bantay")
+	if err != nil {
+		t.Fatalf("can't create body file: %s", err)
+	}
+	defer os.Remove(bodyFile.Name())
+	bodyFile.WriteString(`{"from":"file"}`)
+	bodyFile.Close()
+
+	c := Check{
+		Name:        "a",
+		URL:         srv.URL,
+		ValidStatus: StatusCodes{{200, 200}},
+		Method:      "post",
+		Headers:     map[string]string{"X-Request-Id": "42"},
+		QueryParams: map[string]string{"q": "bantay"},
+		Body:        `{"from":"body"}`,
+		BasicAuth:   &BasicAuth{Username: "user", Password: "secret"},
+	}
+	if res := NewHTTPChecker().Check(context.Background(), c); !res.Success {
+		t.Fatalf("Check() failed: %s", res.Message)
+	}
+	r := <-requests
+	if r.method != http.MethodPost {
+		t.Errorf("method = %s, want POST", r.method)
+	}
+	if r.header.Get("X-Request-Id") != "42" || !strings.HasPrefix(r.header.Get("User-Agent"), "Bantay") {
+		t.Errorf("headers = %v, want X-Request-Id and the Bantay User-Agent", r.header)
+	}
+	if r.query.Get("q") != "bantay" {
+		t.Errorf("query = %v, want q=bantay", r.query)
+	}
+	if r.body != `{"from":"body"}` {
+		t.Errorf("body = %q, want the Check body", r.body)
+	}
+	if auth := r.header.Get("Authorization"); auth != "Basic dXNlcjpzZWNyZXQ=" {
+		t.Errorf("Authorization = %q, want basic credentials", auth)
+	}
+
+	c.Body, c.BodyFile = "", bodyFile.Name()
+	c.BasicAuth, c.BearerToken = nil, "token"
+	if res := NewHTTPChecker().Check(context.Background(), c); !res.Success {
+		t.Fatalf("Check() failed: %s", res.Message)
+	}
+	r = <-requests
+	if r.body != `{"from":"file"}` {
+		t.Errorf("body = %q, want the contents of body_file", r.body)
+	}
+	if auth := r.header.Get("Authorization"); auth != "Bearer token" {
+		t.Errorf("Authorization = %q, want the bearer token", auth)
+	}
+}
+
+func TestHTTPCheckerValidate(t *testing.T) {
+	valid := Check{URL: "http://example.com", ValidStatus: StatusCodes{{200, 200}}}
+	tests := []struct {
+		name   string
+		modify func(c *Check)
+		valid  bool
+	}{
+		{"valid", func(c *Check) {}, true},
+		{"no url", func(c *Check) { c.URL = "" }, false},
+		{"no valid_status", func(c *Check) { c.ValidStatus = nil }, false},
+		{"body and body_file", func(c *Check) { c.Body, c.BodyFile = "a", "b" }, false},
+		{"missing body_file", func(c *Check) { c.BodyFile = "/nonexistent/body.json" }, false},
+		{"basic_auth and bearer_token", func(c *Check) { c.BasicAuth, c.BearerToken = &BasicAuth{}, "token" }, false},
+	}
+	for _, tt := range tests {
+		c := valid
+		tt.modify(&c)
+		if err := NewHTTPChecker().Validate(c); (err == nil) != tt.valid {
+			t.Errorf("Validate() of %s returned %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestHTTPCheckerConnectionReuse(t *testing.T) {
+	tests := []struct {
+		name            string
+		freshConnection bool
+		connections     int32
+	}{
+		{"pooled", false, 1},
+		{"fresh_connection", true, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var connections int32
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			srv.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+				if state == http.StateNew {
+					atomic.AddInt32(&connections, 1)
+				}
+			}
+			srv.Start()
+			defer srv.Close()
+			checker := NewHTTPChecker()
+			c := Check{Name: "a", URL: srv.URL, ValidStatus: StatusCodes{{200, 200}}, FreshConnection: tt.freshConnection}
+			for i := 0; i < 3; i++ {
+				if res := checker.Check(context.Background(), c); !res.Success {
+					t.Fatalf("Check() failed: %s", res.Message)
+				}
+			}
+			if n := atomic.LoadInt32(&connections); n != tt.connections {
+				t.Errorf("3 checks opened %d connections, want %d", n, tt.connections)
+			}
+		})
+	}
+}
+
+func TestHTTPCheckerTiming(t *testing.T) {
+	srv := newTestServer(http.StatusOK, "ok")
+	defer srv.Close()
+	res := NewHTTPChecker().Check(context.Background(), Check{Name: "a", URL: srv.URL, ValidStatus: StatusCodes{{200, 200}}, FreshConnection: true})
+	if res.Timing == nil {
+		t.Fatal("Check() returned no timing")
+	}
+	if res.Timing.TCPConnect <= 0 || res.Timing.FirstByte <= 0 {
+		t.Errorf("Check() timing = %+v, want the connect and first byte phases", *res.Timing)
+	}
+}
+
+func TestHTTPCheckerTimingAfterRedirect(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		http.Redirect(w, r, "/new", http.StatusFound)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	res := NewHTTPChecker().Check(context.Background(), Check{Name: "a", URL: srv.URL + "/old", ValidStatus: StatusCodes{{200, 200}}})
+	if !res.Success || res.Timing == nil {
+		t.Fatalf("Check() = %+v, want a success with timing", res)
+	}
+	// The slow first hop counts towards the latency, but not the timing of the last request
+	if res.Latency < 100*time.Millisecond || res.Timing.FirstByte >= 100*time.Millisecond {
+		t.Errorf("Check() latency %s and first byte after %s, want the first byte of the last request only", res.Latency, res.Timing.FirstByte)
+	}
+}
@@ -0,0 +1,70 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// tcpMaxResponse is the most bytes read from a TCP service while looking for Expect
+const tcpMaxResponse = 64 * 1024
+
+// TCPChecker implements Checker by opening a TCP connection to the Check address
+type TCPChecker struct{}
+
+func init() {
+	RegisterChecker("tcp", TCPChecker{})
+}
+
+// Validate ensures the Check has a host:port address to connect to
+func (tc TCPChecker) Validate(c Check) error {
+	if _, _, err := net.SplitHostPort(c.Address); err != nil {
+		return fmt.Errorf("invalid address %s: %s", c.Address, err.Error())
+	}
+	return nil
+}
+
+// Check connects to the Check address, optionally sending Send and waiting for Expect
+func (tc TCPChecker) Check(ctx context.Context, c Check) CheckResult {
+	start := time.Now()
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", c.Address)
+	if err != nil {
+		return errorResult(ctx, c, err, 0*time.Second)
+	}
+	defer conn.Close()
+	latency := time.Since(start)
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// Send the payload, if any
+	if len(c.Send) > 0 {
+		if _, err := conn.Write([]byte(c.Send)); err != nil {
+			return errorResult(ctx, c, err, latency)
+		}
+	}
+	// Perform check on the response
+	if len(c.Expect) > 0 {
+		expect := []byte(c.Expect)
+		received := make([]byte, 0, 512)
+		buf := make([]byte, 512)
+		for !bytes.Contains(received, expect) {
+			n, err := conn.Read(buf)
+			received = append(received, buf[:n]...)
+			if bytes.Contains(received, expect) {
+				break
+			}
+			if err != nil && isTimeout(ctx, err) {
+				errMsg := fmt.Sprintf("Timed out waiting for string '%s' in response.", c.Expect)
+				return CheckResult{Name: c.Name, Success: false, Reason: ReasonTimeout, Message: errMsg, Latency: latency}
+			}
+			if err != nil || len(received) >= tcpMaxResponse {
+				errMsg := fmt.Sprintf("String '%s' not found in response.", c.Expect)
+				return CheckResult{Name: c.Name, Success: false, Reason: ReasonAssertion, Message: errMsg, Latency: latency}
+			}
+		}
+	}
+	return CheckResult{Name: c.Name, Success: true, Latency: latency}
+}
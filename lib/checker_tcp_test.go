@@ -0,0 +1,85 @@
+package lib
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// newTCPServer returns a listener that writes the banner to every connection, then echoes back
+// whatever it reads
+func newTCPServer(t *testing.T, banner string) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("can't listen: %s", err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				conn.Write([]byte(banner))
+				buf := make([]byte, 512)
+				for {
+					n, err := conn.Read(buf)
+					if err != nil {
+						return
+					}
+					conn.Write(buf[:n])
+				}
+			}(conn)
+		}
+	}()
+	return l
+}
+
+func TestTCPChecker(t *testing.T) {
+	l := newTCPServer(t, "+OK ready\r\n")
+	defer l.Close()
+	tests := []struct {
+		name    string
+		send    string
+		expect  string
+		success bool
+		reason  FailureReason
+	}{
+		{"connect only", "", "", true, ""},
+		{"banner", "", "+OK", true, ""},
+		{"echoed payload", "PING\r\n", "PING", true, ""},
+		{"missing response", "", "PONG", false, ReasonTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			c := Check{Name: "tcp", Type: "tcp", Address: l.Addr().String(), Send: tt.send, Expect: tt.expect}
+			res := TCPChecker{}.Check(ctx, c)
+			if res.Success != tt.success || res.Reason != tt.reason {
+				t.Errorf("Check() = %v with reason %q, want %v with reason %q: %s", res.Success, res.Reason, tt.success, tt.reason, res.Message)
+			}
+		})
+	}
+}
+
+func TestTCPCheckerConnectionRefused(t *testing.T) {
+	l := newTCPServer(t, "")
+	addr := l.Addr().String()
+	l.Close()
+	res := TCPChecker{}.Check(context.Background(), Check{Name: "tcp", Address: addr})
+	if res.Success || res.Reason != ReasonError {
+		t.Errorf("Check() of a closed port = %+v, want a failure with reason error", res)
+	}
+}
+
+func TestTCPCheckerValidate(t *testing.T) {
+	if err := (TCPChecker{}).Validate(Check{Address: "localhost:5432"}); err != nil {
+		t.Errorf("Validate() of host:port returned error: %s", err)
+	}
+	if err := (TCPChecker{}).Validate(Check{Address: "localhost"}); err == nil {
+		t.Error("Validate() of an address without port returned no error")
+	}
+}
@@ -0,0 +1,79 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetChecker(t *testing.T) {
+	checker, err := GetChecker("")
+	if err != nil {
+		t.Fatalf("GetChecker(\"\") returned error: %s", err)
+	}
+	if _, ok := checker.(HTTPChecker); !ok {
+		t.Errorf("GetChecker(\"\") = %T, want HTTPChecker", checker)
+	}
+	for _, checkType := range []string{"http", "tcp", "tls", "dns"} {
+		if _, err := GetChecker(checkType); err != nil {
+			t.Errorf("GetChecker(%q) returned error: %s", checkType, err)
+		}
+	}
+	if _, err := GetChecker("ftp"); err == nil {
+		t.Error("GetChecker(\"ftp\") returned no error")
+	}
+}
+
+// stubChecker returns the given results in turn, recording how many times it was run
+type stubChecker struct {
+	results []CheckResult
+	runs    int
+}
+
+func (sc *stubChecker) Validate(c Check) error {
+	return nil
+}
+
+func (sc *stubChecker) Check(ctx context.Context, c Check) CheckResult {
+	res := sc.results[sc.runs]
+	sc.runs++
+	return res
+}
+
+func TestRunCheckDispatchesOnType(t *testing.T) {
+	stub := &stubChecker{results: []CheckResult{{Name: "stub", Success: true}}}
+	RegisterChecker("stub", stub)
+	defer delete(checkers, "stub")
+
+	res := runCheck(context.Background(), Check{Name: "stub", Type: "stub"})
+	if !res.Success || stub.runs != 1 {
+		t.Errorf("runCheck() = %+v after %d runs, want a success after 1 run", res, stub.runs)
+	}
+	res = runCheck(context.Background(), Check{Name: "unknown", Type: "unknown"})
+	if res.Success || res.Reason != ReasonError {
+		t.Errorf("runCheck() of unknown type = %+v, want a failure with reason error", res)
+	}
+}
+
+func TestErrorResult(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	if res := errorResult(ctx, Check{Name: "a"}, errors.New("boom"), 0); res.Reason != ReasonTimeout {
+		t.Errorf("errorResult() after deadline has reason %q, want timeout", res.Reason)
+	}
+	if res := errorResult(context.Background(), Check{Name: "a"}, errors.New("boom"), 0); res.Reason != ReasonError {
+		t.Errorf("errorResult() has reason %q, want error", res.Reason)
+	}
+}
+
+// newTestServer returns a server that responds with the given status and body
+func newTestServer(status int, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+}
@@ -0,0 +1,98 @@
+package lib
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// TLSChecker implements Checker by performing a TLS handshake with the Check address
+type TLSChecker struct{}
+
+func init() {
+	RegisterChecker("tls", TLSChecker{})
+}
+
+// Validate ensures the Check has a host:port address and sane expiry thresholds
+func (tc TLSChecker) Validate(c Check) error {
+	if _, _, err := net.SplitHostPort(c.Address); err != nil {
+		return fmt.Errorf("invalid address %s: %s", c.Address, err.Error())
+	}
+	return validateCertExpiry(c)
+}
+
+// Check performs a TLS handshake, validating the hostname and certificate chain of the peer
+func (tc TLSChecker) Check(ctx context.Context, c Check) CheckResult {
+	serverName := c.ServerName
+	if len(serverName) == 0 {
+		serverName, _, _ = net.SplitHostPort(c.Address)
+	}
+	start := time.Now()
+	dialer := net.Dialer{}
+	rawConn, err := dialer.DialContext(ctx, "tcp", c.Address)
+	if err != nil {
+		return errorResult(ctx, c, err, 0*time.Second)
+	}
+	defer rawConn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		rawConn.SetDeadline(deadline)
+	}
+	conn := tls.Client(rawConn, &tls.Config{ServerName: serverName})
+	if err := conn.Handshake(); err != nil {
+		if isTimeout(ctx, err) {
+			return errorResult(ctx, c, err, 0*time.Second)
+		}
+		errMsg := fmt.Sprintf("TLS handshake failed: %s", err.Error())
+		return CheckResult{Name: c.Name, Success: false, Reason: ReasonCertificate, Message: errMsg, Latency: 0 * time.Second}
+	}
+	result := CheckResult{Name: c.Name, Success: true, Latency: time.Since(start)}
+	state := conn.ConnectionState()
+	return checkCertExpiry(c, &state, result)
+}
+
+// validateCertExpiry ensures the certificate expiry thresholds of a Check are sane
+func validateCertExpiry(c Check) error {
+	if c.CertExpiryDays < 0 || c.CertWarningDays < 0 {
+		return errors.New("cert_expiry_days and cert_warning_days can't be negative")
+	}
+	if c.CertWarningDays > 0 && c.CertWarningDays < c.CertExpiryDays {
+		return fmt.Errorf("cert_warning_days (%d) must not be less than cert_expiry_days (%d)", c.CertWarningDays, c.CertExpiryDays)
+	}
+	return nil
+}
+
+// checkCertExpiry records the earliest expiry in the peer certificate chain on res and fails or
+// degrades it when the expiry falls within the thresholds of the Check
+func checkCertExpiry(c Check, state *tls.ConnectionState, res CheckResult) CheckResult {
+	if state == nil {
+		return res
+	}
+	chain := state.PeerCertificates
+	if len(state.VerifiedChains) > 0 {
+		chain = state.VerifiedChains[0]
+	}
+	for _, cert := range chain {
+		if res.CertExpiry.IsZero() || cert.NotAfter.Before(res.CertExpiry) {
+			res.CertExpiry = cert.NotAfter
+		}
+	}
+	if res.CertExpiry.IsZero() {
+		return res
+	}
+	daysRemaining := res.CertDaysRemaining()
+	// Append to the message, rather than replace it, to keep what the other assertions captured
+	note := fmt.Sprintf("Certificate expires in %d days, on %s.", daysRemaining, res.CertExpiry.Format("2006-01-02"))
+	if c.CertExpiryDays > 0 && daysRemaining < c.CertExpiryDays {
+		res.Success = false
+		res.Reason = ReasonCertificate
+		res.Message = strings.TrimSpace(res.Message + " " + note)
+	} else if c.CertWarningDays > 0 && daysRemaining < c.CertWarningDays {
+		res.Degraded = true
+		res.Message = strings.TrimSpace(res.Message + " " + note)
+	}
+	return res
+}
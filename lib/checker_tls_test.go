@@ -0,0 +1,77 @@
+package lib
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// connectionState returns a tls.ConnectionState with a peer certificate expiring in the given days
+func connectionState(days int) *tls.ConnectionState {
+	notAfter := time.Now().Add(time.Duration(days)*24*time.Hour + time.Hour)
+	return &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{NotAfter: notAfter}}}
+}
+
+func TestCheckCertExpiry(t *testing.T) {
+	c := Check{Name: "tls", CertExpiryDays: 7, CertWarningDays: 30}
+	tests := []struct {
+		name     string
+		days     int
+		success  bool
+		degraded bool
+	}{
+		{"far from expiry", 90, true, false},
+		{"within warning", 20, true, true},
+		{"within expiry", 3, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := checkCertExpiry(c, connectionState(tt.days), CheckResult{Name: c.Name, Success: true})
+			if res.Success != tt.success || res.Degraded != tt.degraded {
+				t.Errorf("checkCertExpiry() = success %v, degraded %v, want %v, %v", res.Success, res.Degraded, tt.success, tt.degraded)
+			}
+			if res.CertDaysRemaining() != tt.days {
+				t.Errorf("CertDaysRemaining() = %d, want %d", res.CertDaysRemaining(), tt.days)
+			}
+		})
+	}
+}
+
+func TestCheckCertExpiryKeepsMessage(t *testing.T) {
+	c := Check{Name: "tls", CertWarningDays: 30}
+	res := checkCertExpiry(c, connectionState(20), CheckResult{Name: c.Name, Success: true, Message: "Captured version 1.2."})
+	if !strings.HasPrefix(res.Message, "Captured version 1.2. Certificate expires in 20 days") {
+		t.Errorf("checkCertExpiry() message = %q, want the expiry appended to the captured one", res.Message)
+	}
+	res = checkCertExpiry(c, nil, CheckResult{Name: c.Name, Success: true, Message: "Captured version 1.2."})
+	if res.Message != "Captured version 1.2." || !res.CertExpiry.IsZero() {
+		t.Errorf("checkCertExpiry() without TLS = %+v, want it unchanged", res)
+	}
+}
+
+func TestTLSCheckerUntrustedCertificate(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "https://")
+	res := TLSChecker{}.Check(context.Background(), Check{Name: "tls", Type: "tls", Address: addr})
+	if res.Success || res.Reason != ReasonCertificate {
+		t.Errorf("Check() of a self-signed certificate = %+v, want a failure with reason certificate", res)
+	}
+}
+
+func TestValidateCertExpiry(t *testing.T) {
+	if err := validateCertExpiry(Check{CertExpiryDays: 7, CertWarningDays: 30}); err != nil {
+		t.Errorf("validateCertExpiry() returned error: %s", err)
+	}
+	if err := validateCertExpiry(Check{CertExpiryDays: 30, CertWarningDays: 7}); err == nil {
+		t.Error("validateCertExpiry() with warning below expiry returned no error")
+	}
+	if err := validateCertExpiry(Check{CertExpiryDays: -1}); err == nil {
+		t.Error("validateCertExpiry() with negative days returned no error")
+	}
+}
@@ -0,0 +1,366 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KixPanganiban/bantay/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+// DefaultHistoryRetentionDays is how long results are kept when the server doesn't set it
+const DefaultHistoryRetentionDays = 90
+
+// DefaultHistoryFlushInterval is how often results are written when the server doesn't set it
+const DefaultHistoryFlushInterval = 10 * time.Second
+
+// BoltDB buckets holding a bucket per check, each keyed by the time of its results or incidents,
+// or by the day of its daily summaries
+var (
+	resultsBucket   = []byte("results")
+	incidentsBucket = []byte("incidents")
+	daysBucket      = []byte("days")
+)
+
+// historyDayFormat is how HistoryDays are keyed, by their day in local time
+const historyDayFormat = "2006-01-02"
+
+// HistoryRecord is a CheckResult along with when it was recorded
+type HistoryRecord struct {
+	Time   time.Time   `json:"time"`
+	Result CheckResult `json:"result"`
+}
+
+// HistoryDay summarizes the results of a check on a day, so that uptime over many days can be
+// computed without reading every result
+type HistoryDay struct {
+	Day     string `json:"day"`
+	Results int    `json:"results"`
+	// First is when the first result of the day was recorded
+	First time.Time `json:"first"`
+}
+
+// HistoryStore implements Reporter by keeping every CheckResult, and every incident of a check
+// that went down, in an embedded BoltDB file. Results are buffered and written every flush, and
+// the file is only open while writing or reading, so that other processes can read it in between.
+type HistoryStore struct {
+	Path      string
+	Retention time.Duration
+	// mu guards the results and incidents waiting to be written
+	mu        sync.Mutex
+	results   []HistoryRecord
+	incidents map[string][]Incident
+	// dbMu serializes opening the file, which BoltDB locks while open
+	dbMu sync.Mutex
+}
+
+// NewHistoryStore returns the HistoryStore configured under server history
+func NewHistoryStore(sc ParsedHistory) *HistoryStore {
+	retentionDays := sc.RetentionDays
+	if retentionDays <= 0 {
+		retentionDays = DefaultHistoryRetentionDays
+	}
+	return &HistoryStore{
+		Path:      sc.Path,
+		Retention: time.Duration(retentionDays) * 24 * time.Hour,
+	}
+}
+
+// validateHistory ensures the server history config is sane
+func validateHistory(sc ParsedHistory) error {
+	if sc.RetentionDays < 0 {
+		return errors.New("history retention_days can't be negative")
+	}
+	if sc.FlushInterval < 0 {
+		return errors.New("history flush_interval can't be negative")
+	}
+	return validateDuration("history flush_interval", sc.FlushInterval, time.Second)
+}
+
+// Report buffers the CheckResult, and the incident it is part of if the check went down, to be
+// written on the next Flush
+func (hs *HistoryStore) Report(ctx context.Context, ev Event) error {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.results = append(hs.results, HistoryRecord{Time: time.Now(), Result: ev.Result})
+	if ev.Incident != nil && ev.Incident.Down {
+		if hs.incidents == nil {
+			hs.incidents = make(map[string][]Incident)
+		}
+		hs.incidents[ev.Result.Name] = append(hs.incidents[ev.Result.Name], *ev.Incident)
+	}
+	return nil
+}
+
+// Flush writes buffered results and incidents, and drops those older than the Retention
+func (hs *HistoryStore) Flush() error {
+	hs.mu.Lock()
+	results, incidents := hs.results, hs.incidents
+	hs.results, hs.incidents = nil, nil
+	hs.mu.Unlock()
+	err := hs.update(func(tx *bolt.Tx) error {
+		for _, record := range results {
+			v, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+			if err := putHistory(tx, resultsBucket, record.Result.Name, record.Time, v); err != nil {
+				return err
+			}
+			if err := addHistoryDay(tx, record); err != nil {
+				return err
+			}
+		}
+		// Incidents are keyed by their start, so later updates of an incident replace earlier ones
+		for name, updates := range incidents {
+			for _, incident := range updates {
+				v, err := json.Marshal(incident)
+				if err != nil {
+					return err
+				}
+				if err := putHistory(tx, incidentsBucket, name, incident.Start, v); err != nil {
+					return err
+				}
+			}
+		}
+		cutoff := time.Now().Add(-hs.Retention)
+		cutoffs := map[string][]byte{
+			string(resultsBucket):   historyKey(cutoff),
+			string(incidentsBucket): historyKey(cutoff),
+			string(daysBucket):      []byte(cutoff.Local().Format(historyDayFormat)),
+		}
+		for name, cutoff := range cutoffs {
+			bucket := tx.Bucket([]byte(name))
+			err := bucket.ForEach(func(check []byte, _ []byte) error {
+				c := bucket.Bucket(check).Cursor()
+				for k, _ := c.First(); k != nil && bytes.Compare(k, cutoff) < 0; k, _ = c.First() {
+					if err := c.Delete(); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// Put the results and incidents back ahead of any added since, to be written on the next Flush
+		hs.mu.Lock()
+		hs.results = append(results, hs.results...)
+		if hs.incidents == nil {
+			hs.incidents = make(map[string][]Incident)
+		}
+		for name, updates := range incidents {
+			hs.incidents[name] = append(updates, hs.incidents[name]...)
+		}
+		hs.mu.Unlock()
+	}
+	return err
+}
+
+// Run flushes the HistoryStore every interval until the context is done, then flushes it one
+// last time
+func (hs *HistoryStore) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultHistoryFlushInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := hs.Flush(); err != nil {
+				log.Warnf("Unable to write history: %s", err.Error())
+			}
+			return
+		case <-ticker.C:
+			if err := hs.Flush(); err != nil {
+				log.Warnf("Unable to write history: %s", err.Error())
+			}
+		}
+	}
+}
+
+// Results returns the written results of the named check since the given time, oldest first
+func (hs *HistoryStore) Results(name string, since time.Time) ([]HistoryRecord, error) {
+	records := []HistoryRecord{}
+	err := hs.view(resultsBucket, name, historyKey(since), func(v []byte) error {
+		var record HistoryRecord
+		if err := json.Unmarshal(v, &record); err != nil {
+			return err
+		}
+		records = append(records, record)
+		return nil
+	})
+	return records, err
+}
+
+// Incidents returns the written incidents of the named check that started since the given time,
+// oldest first. Incidents that haven't ended have a zero End.
+func (hs *HistoryStore) Incidents(name string, since time.Time) ([]Incident, error) {
+	incidents := []Incident{}
+	err := hs.view(incidentsBucket, name, historyKey(since), func(v []byte) error {
+		var incident Incident
+		if err := json.Unmarshal(v, &incident); err != nil {
+			return err
+		}
+		incidents = append(incidents, incident)
+		return nil
+	})
+	return incidents, err
+}
+
+// Days returns the daily summaries of the named check since the day of the given time, oldest
+// first. Days without results are left out.
+func (hs *HistoryStore) Days(name string, since time.Time) ([]HistoryDay, error) {
+	days := []HistoryDay{}
+	err := hs.view(daysBucket, name, []byte(since.Local().Format(historyDayFormat)), func(v []byte) error {
+		var day HistoryDay
+		if err := json.Unmarshal(v, &day); err != nil {
+			return err
+		}
+		days = append(days, day)
+		return nil
+	})
+	return days, err
+}
+
+// update opens the file for writing, creating it and its buckets if needed, and runs fn in a
+// single transaction
+func (hs *HistoryStore) update(fn func(*bolt.Tx) error) error {
+	hs.dbMu.Lock()
+	defer hs.dbMu.Unlock()
+	db, err := bolt.Open(hs.Path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return fmt.Errorf("can't open history database %s: %s", hs.Path, err.Error())
+	}
+	defer db.Close()
+	return db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{resultsBucket, incidentsBucket, daysBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return fn(tx)
+	})
+}
+
+// view opens the file for reading and passes every value of the named check in the given bucket,
+// from the given key on, to fn, oldest first
+func (hs *HistoryStore) view(bucket []byte, name string, from []byte, fn func([]byte) error) error {
+	hs.dbMu.Lock()
+	defer hs.dbMu.Unlock()
+	// Nothing was written yet
+	if _, err := os.Stat(hs.Path); os.IsNotExist(err) {
+		return nil
+	}
+	db, err := bolt.Open(hs.Path, 0600, &bolt.Options{Timeout: 5 * time.Second, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("can't open history database %s: %s", hs.Path, err.Error())
+	}
+	defer db.Close()
+	return db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket(bucket)
+		if root == nil {
+			return nil
+		}
+		check := root.Bucket([]byte(name))
+		if check == nil {
+			return nil
+		}
+		c := check.Cursor()
+		for k, v := c.Seek(from); k != nil; k, v = c.Next() {
+			if err := fn(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// putHistory stores the value under the given time in the bucket of the named check
+func putHistory(tx *bolt.Tx, bucket []byte, name string, at time.Time, v []byte) error {
+	check, err := tx.Bucket(bucket).CreateBucketIfNotExists([]byte(name))
+	if err != nil {
+		return err
+	}
+	return check.Put(historyKey(at), v)
+}
+
+// addHistoryDay counts the HistoryRecord in the summary of its day
+func addHistoryDay(tx *bolt.Tx, record HistoryRecord) error {
+	check, err := tx.Bucket(daysBucket).CreateBucketIfNotExists([]byte(record.Result.Name))
+	if err != nil {
+		return err
+	}
+	key := record.Time.Local().Format(historyDayFormat)
+	day := HistoryDay{Day: key}
+	if v := check.Get([]byte(key)); v != nil {
+		if err := json.Unmarshal(v, &day); err != nil {
+			return err
+		}
+	}
+	day.Results++
+	if day.First.IsZero() || record.Time.Before(day.First) {
+		day.First = record.Time
+	}
+	v, err := json.Marshal(day)
+	if err != nil {
+		return err
+	}
+	return check.Put([]byte(key), v)
+}
+
+// historyKey encodes the time so that keys sort in chronological order, with times before the
+// Unix epoch, such as the zero time, sorting first
+func historyKey(at time.Time) []byte {
+	k := make([]byte, 8)
+	if at.After(time.Unix(0, 0)) {
+		binary.BigEndian.PutUint64(k, uint64(at.UnixNano()))
+	}
+	return k
+}
+
+// Percentile returns the latency below which the given percent of latencies fall, using the
+// nearest-rank method
+func Percentile(latencies []time.Duration, percent float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := int(math.Ceil(percent / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// ParseDuration parses a duration like time.ParseDuration does, also accepting whole days like 30d
+func ParseDuration(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || days < 0 {
+			return 0, fmt.Errorf("invalid duration %s", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
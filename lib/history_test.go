@@ -0,0 +1,152 @@
+package lib
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestHistoryStore returns a HistoryStore in a temporary directory, which the caller removes
+func newTestHistoryStore(t *testing.T) (*HistoryStore, string) {
+	dir := tempDir(t)
+	return NewHistoryStore(ParsedHistory{Path: filepath.Join(dir, "history.db"), RetentionDays: 7}), dir
+}
+
+func TestHistoryStoreFlush(t *testing.T) {
+	hs, dir := newTestHistoryStore(t)
+	defer os.RemoveAll(dir)
+	if records, err := hs.Results("a", time.Time{}); err != nil || len(records) != 0 {
+		t.Errorf("Results() before any flush = %v, %v, want none", records, err)
+	}
+	store := NewIncidentStore()
+	c := Check{Name: "a"}
+	for _, res := range []CheckResult{
+		{Name: "a", Success: true, Latency: time.Millisecond},
+		{Name: "a", Success: false, Reason: ReasonStatus},
+		{Name: "b", Success: true},
+	} {
+		hs.Report(context.Background(), store.Track(Check{Name: res.Name}, res))
+	}
+	if err := hs.Flush(); err != nil {
+		t.Fatalf("Flush() returned error: %s", err)
+	}
+	records, err := hs.Results("a", time.Time{})
+	if err != nil || len(records) != 2 {
+		t.Fatalf("Results(a) = %v, %v, want 2 results", records, err)
+	}
+	if !records[0].Result.Success || records[1].Result.Reason != ReasonStatus || records[0].Time.After(records[1].Time) {
+		t.Errorf("Results(a) = %+v, want the results oldest first", records)
+	}
+	if records, _ := hs.Results("a", time.Now()); len(records) != 0 {
+		t.Errorf("Results(a) since now = %v, want none", records)
+	}
+
+	// The ongoing incident is replaced once it ends
+	incidents, err := hs.Incidents("a", time.Time{})
+	if err != nil || len(incidents) != 1 || !incidents[0].End.IsZero() {
+		t.Fatalf("Incidents(a) = %+v, %v, want the ongoing incident", incidents, err)
+	}
+	hs.Report(context.Background(), store.Track(c, CheckResult{Name: "a", Success: true}))
+	hs.Flush()
+	incidents, _ = hs.Incidents("a", time.Time{})
+	if len(incidents) != 1 || incidents[0].End.IsZero() {
+		t.Errorf("Incidents(a) after recovery = %+v, want the ended incident", incidents)
+	}
+	if incidents, _ := hs.Incidents("b", time.Time{}); len(incidents) != 0 {
+		t.Errorf("Incidents(b) = %+v, want none", incidents)
+	}
+}
+
+func TestHistoryStoreFlushFailureKeepsBuffer(t *testing.T) {
+	hs, dir := newTestHistoryStore(t)
+	defer os.RemoveAll(dir)
+	path := hs.Path
+	// A path inside a directory that doesn't exist can't be opened
+	hs.Path = filepath.Join(dir, "missing", "history.db")
+	store := NewIncidentStore()
+	hs.Report(context.Background(), store.Track(Check{Name: "a"}, CheckResult{Name: "a", Success: false, Message: "first"}))
+	if err := hs.Flush(); err == nil {
+		t.Fatal("Flush() to a missing directory returned no error")
+	}
+	hs.Report(context.Background(), Event{Result: CheckResult{Name: "a", Success: true, Message: "second"}})
+	hs.Path = path
+	if err := hs.Flush(); err != nil {
+		t.Fatalf("Flush() returned error: %s", err)
+	}
+	records, _ := hs.Results("a", time.Time{})
+	if len(records) != 2 || records[0].Result.Message != "first" || records[1].Result.Message != "second" {
+		t.Errorf("Results() = %+v, want the result of the failed flush before the later one", records)
+	}
+	if incidents, _ := hs.Incidents("a", time.Time{}); len(incidents) != 1 {
+		t.Errorf("Incidents() = %+v, want the incident of the failed flush", incidents)
+	}
+}
+
+func TestHistoryStoreRetention(t *testing.T) {
+	hs, dir := newTestHistoryStore(t)
+	defer os.RemoveAll(dir)
+	now := time.Now()
+	hs.results = []HistoryRecord{
+		{Time: now.AddDate(0, 0, -8), Result: CheckResult{Name: "a", Message: "expired"}},
+		{Time: now.AddDate(0, 0, -6), Result: CheckResult{Name: "a", Message: "kept"}},
+	}
+	if err := hs.Flush(); err != nil {
+		t.Fatalf("Flush() returned error: %s", err)
+	}
+	records, _ := hs.Results("a", time.Time{})
+	if len(records) != 1 || records[0].Result.Message != "kept" {
+		t.Errorf("Results() = %+v, want only the result within the 7 days of retention", records)
+	}
+}
+
+func TestHistoryStoreRunFlushesOnShutdown(t *testing.T) {
+	hs, dir := newTestHistoryStore(t)
+	defer os.RemoveAll(dir)
+	hs.Report(context.Background(), Event{Result: CheckResult{Name: "a", Success: true}})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	hs.Run(ctx, time.Hour)
+	if records, _ := hs.Results("a", time.Time{}); len(records) != 1 {
+		t.Errorf("Results() after shutdown = %v, want the reported result", records)
+	}
+}
+
+func TestValidateHistory(t *testing.T) {
+	if err := validateHistory(ParsedHistory{Path: "history.db", RetentionDays: 30, FlushInterval: time.Minute}); err != nil {
+		t.Errorf("validateHistory() returned error: %s", err)
+	}
+	if err := validateHistory(ParsedHistory{RetentionDays: -1}); err == nil {
+		t.Error("validateHistory() with negative retention_days returned no error")
+	}
+}
+
+func TestHistoryStoreDays(t *testing.T) {
+	hs, dir := newTestHistoryStore(t)
+	defer os.RemoveAll(dir)
+	// Midday keeps a second before it on the same day, whatever time the test runs at
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	hs.results = []HistoryRecord{
+		{Time: yesterday, Result: CheckResult{Name: "a", Success: true}},
+		{Time: today.Add(-time.Second), Result: CheckResult{Name: "a", Success: true}},
+	}
+	hs.Flush()
+	hs.results = []HistoryRecord{{Time: today, Result: CheckResult{Name: "a", Success: false}}}
+	hs.Flush()
+	days, err := hs.Days("a", yesterday)
+	if err != nil || len(days) != 2 {
+		t.Fatalf("Days() = %+v, %v, want yesterday and today", days, err)
+	}
+	if days[0].Day != yesterday.Format("2006-01-02") || days[0].Results != 1 || !days[0].First.Equal(yesterday) {
+		t.Errorf("Days() yesterday = %+v, want 1 result", days[0])
+	}
+	if days[1].Results != 2 || !days[1].First.Equal(today.Add(-time.Second)) {
+		t.Errorf("Days() today = %+v, want 2 results across flushes, starting with the first", days[1])
+	}
+	if days, _ := hs.Days("a", today); len(days) != 1 {
+		t.Errorf("Days() since today = %+v, want only today", days)
+	}
+}
@@ -0,0 +1,256 @@
+package lib
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// maxIncidentAttempts is the most results kept in the history of an incident
+const maxIncidentAttempts = 50
+
+// EventType is the transition, if any, that a CheckResult caused
+type EventType string
+
+// Event types passed to reporters along with every CheckResult
+const (
+	EventNone      EventType = ""
+	EventWentDown  EventType = "went_down"
+	EventStillDown EventType = "still_down"
+	EventRecovered EventType = "recovered"
+	EventDegraded  EventType = "degraded"
+)
+
+// Event is a CheckResult along with the transition it caused in the state of its check. Reporters
+// that send alerts should act on the Type rather than track results themselves.
+type Event struct {
+	Type   EventType
+	Result CheckResult
+	// Incident is the incident the result is part of, if any, as of this result
+	Incident *Incident
+	// Downtime is how long the check has been down for, or was down for once it recovered
+	Downtime time.Duration
+}
+
+// Attempt is the outcome of a single run of a check, kept in the history of an incident
+type Attempt struct {
+	Time     time.Time     `json:"time"`
+	Success  bool          `json:"success"`
+	Reason   FailureReason `json:"reason,omitempty"`
+	Message  string        `json:"message"`
+	Latency  time.Duration `json:"latency"`
+	Attempts int           `json:"attempts"`
+}
+
+// Incident is a streak of failed results of a check, which becomes an outage once the check went
+// down, and ends once it recovers
+type Incident struct {
+	Start time.Time `json:"start"`
+	// Down is set once the incident reached alert_after consecutive failures
+	Down bool `json:"down"`
+	// FirstFailure is the first failed result of the incident
+	FirstFailure Attempt `json:"first_failure"`
+	// FailedCount is the number of failed results in the incident
+	FailedCount int `json:"failed_count"`
+	// RecoveringSince is when the current streak of successes started, if any
+	RecoveringSince time.Time `json:"recovering_since"`
+	End             time.Time `json:"end"`
+	// Attempts is the history of results in the incident, up to the latest maxIncidentAttempts
+	Attempts []Attempt `json:"attempts"`
+}
+
+// copy returns a deep copy of the Incident, safe to hand out while the original keeps changing
+func (i *Incident) copy() *Incident {
+	if i == nil {
+		return nil
+	}
+	c := *i
+	c.Attempts = append([]Attempt(nil), i.Attempts...)
+	return &c
+}
+
+// CheckState is the current state of a check, along with its ongoing incident, if any
+type CheckState struct {
+	Name string `json:"name"`
+	// State is one of up, degraded or down
+	State                string      `json:"state"`
+	LastTransition       time.Time   `json:"last_transition"`
+	LastChecked          time.Time   `json:"last_checked"`
+	LastResult           CheckResult `json:"last_result"`
+	ConsecutiveFailures  int         `json:"consecutive_failures"`
+	ConsecutiveSuccesses int         `json:"consecutive_successes"`
+	Incident             *Incident   `json:"incident,omitempty"`
+}
+
+// IncidentStore keeps the state of every check, deciding from consecutive results when a check
+// went down, is still down, recovered or became degraded
+type IncidentStore struct {
+	mu     sync.Mutex
+	states map[string]*CheckState
+	// dirty is set when states changed since the last Snapshot
+	dirty bool
+}
+
+// NewIncidentStore returns an IncidentStore with no check history
+func NewIncidentStore() *IncidentStore {
+	return &IncidentStore{states: make(map[string]*CheckState)}
+}
+
+// Track records the result of the Check and returns the Event it caused, going down after
+// AlertAfter consecutive failures and recovering after RecoverAfter consecutive successes
+func (is *IncidentStore) Track(c Check, res CheckResult) Event {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	is.dirty = true
+	now := time.Now()
+	state, ok := is.states[c.Name]
+	if !ok {
+		state = &CheckState{Name: c.Name, State: "up", LastTransition: now}
+		is.states[c.Name] = state
+	}
+	alertAfter, recoverAfter := c.AlertAfter, c.RecoverAfter
+	if alertAfter < 1 {
+		alertAfter = 1
+	}
+	if recoverAfter < 1 {
+		recoverAfter = 1
+	}
+	state.LastChecked = now
+	state.LastResult = res
+	attempt := Attempt{
+		Time:     now,
+		Success:  res.Success,
+		Reason:   res.Reason,
+		Message:  res.Message,
+		Latency:  res.Latency,
+		Attempts: res.Attempts,
+	}
+	ev := Event{Type: EventNone, Result: res}
+	if !res.Success {
+		state.ConsecutiveFailures++
+		state.ConsecutiveSuccesses = 0
+		if state.Incident == nil {
+			state.Incident = &Incident{Start: now, FirstFailure: attempt}
+		}
+		incident := state.Incident
+		incident.FailedCount++
+		incident.RecoveringSince = time.Time{}
+		incident.record(attempt)
+		if incident.Down {
+			ev.Type = EventStillDown
+		} else if state.ConsecutiveFailures >= alertAfter {
+			incident.Down = true
+			state.transition("down", now)
+			ev.Type = EventWentDown
+		}
+		ev.Incident = incident.copy()
+		ev.Downtime = now.Sub(incident.Start)
+		return ev
+	}
+	state.ConsecutiveSuccesses++
+	state.ConsecutiveFailures = 0
+	if incident := state.Incident; incident != nil {
+		incident.record(attempt)
+		if incident.RecoveringSince.IsZero() {
+			incident.RecoveringSince = now
+		}
+		if incident.Down && state.ConsecutiveSuccesses < recoverAfter {
+			ev.Incident = incident.copy()
+			ev.Downtime = now.Sub(incident.Start)
+			return ev
+		}
+		// The incident is over, either because the check recovered or never went down
+		incident.End = incident.RecoveringSince
+		if incident.Down {
+			ev.Type = EventRecovered
+			ev.Incident = incident.copy()
+			ev.Downtime = incident.End.Sub(incident.Start)
+		}
+		state.Incident = nil
+	}
+	status := res.Status()
+	// A check that recovered straight into degraded reports the recovery, which carries the result
+	if ev.Type == EventNone && status == "degraded" && state.State != "degraded" {
+		ev.Type = EventDegraded
+	}
+	state.transition(status, now)
+	return ev
+}
+
+// State returns the current state of the named check
+func (is *IncidentStore) State(name string) (CheckState, bool) {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	state, ok := is.states[name]
+	if !ok {
+		return CheckState{}, false
+	}
+	s := *state
+	s.Incident = state.Incident.copy()
+	return s, true
+}
+
+// Snapshot returns the state of every check, and whether any changed since the last Snapshot
+func (is *IncidentStore) Snapshot() ([]CheckState, bool) {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	states := make([]CheckState, 0, len(is.states))
+	for _, state := range is.states {
+		s := *state
+		s.Incident = state.Incident.copy()
+		states = append(states, s)
+	}
+	dirty := is.dirty
+	is.dirty = false
+	return states, dirty
+}
+
+// markDirty flags the states as changed, so that the next Snapshot reports them as such
+func (is *IncidentStore) markDirty() {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	is.dirty = true
+}
+
+// Restore replaces the state of the given checks with previously saved states, such as those
+// loaded from a StateBackend. States of checks that are no longer configured are dropped.
+func (is *IncidentStore) Restore(states []CheckState, cs []Check) {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	configured := make(map[string]bool, len(cs))
+	for _, c := range cs {
+		configured[c.Name] = true
+	}
+	for i := range states {
+		state := states[i]
+		if !configured[state.Name] {
+			continue
+		}
+		state.Incident = state.Incident.copy()
+		is.states[state.Name] = &state
+	}
+}
+
+// record adds the Attempt to the history of the Incident, dropping the oldest beyond maxIncidentAttempts
+func (i *Incident) record(a Attempt) {
+	i.Attempts = append(i.Attempts, a)
+	if len(i.Attempts) > maxIncidentAttempts {
+		i.Attempts = i.Attempts[len(i.Attempts)-maxIncidentAttempts:]
+	}
+}
+
+// transition moves the CheckState to the given state, recording when it last changed
+func (cs *CheckState) transition(state string, at time.Time) {
+	if cs.State != state {
+		cs.State = state
+		cs.LastTransition = at
+	}
+}
+
+// validateAlerting ensures the alerting thresholds of the Check are sane
+func validateAlerting(c Check) error {
+	if c.AlertAfter < 0 || c.RecoverAfter < 0 {
+		return errors.New("alert_after and recover_after can't be negative")
+	}
+	return nil
+}
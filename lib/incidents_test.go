@@ -0,0 +1,73 @@
+package lib
+
+import (
+	"testing"
+)
+
+func TestIncidentStoreTrack(t *testing.T) {
+	up := CheckResult{Name: "a", Success: true}
+	degraded := CheckResult{Name: "a", Success: true, Degraded: true}
+	down := CheckResult{Name: "a", Success: false, Reason: ReasonStatus}
+	tests := []struct {
+		name         string
+		alertAfter   int
+		recoverAfter int
+		results      []CheckResult
+		events       []EventType
+		state        string
+	}{
+		{
+			"up, down and recovered", 1, 1,
+			[]CheckResult{up, down, down, up},
+			[]EventType{EventNone, EventWentDown, EventStillDown, EventRecovered},
+			"up",
+		},
+		{
+			"recovered into degraded", 1, 1,
+			[]CheckResult{up, down, degraded, degraded, up, degraded},
+			[]EventType{EventNone, EventWentDown, EventRecovered, EventNone, EventNone, EventDegraded},
+			"degraded",
+		},
+		{
+			"flapping below alert_after", 3, 1,
+			[]CheckResult{down, down, up, down, down, up},
+			[]EventType{EventNone, EventNone, EventNone, EventNone, EventNone, EventNone},
+			"up",
+		},
+		{
+			"flapping below recover_after", 2, 3,
+			[]CheckResult{down, down, up, up, down, up, up, up},
+			[]EventType{EventNone, EventWentDown, EventNone, EventNone, EventStillDown, EventNone, EventNone, EventRecovered},
+			"up",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewIncidentStore()
+			c := Check{Name: "a", AlertAfter: tt.alertAfter, RecoverAfter: tt.recoverAfter}
+			for i, res := range tt.results {
+				if ev := store.Track(c, res); ev.Type != tt.events[i] {
+					t.Errorf("Track() of result %d = %q, want %q", i+1, ev.Type, tt.events[i])
+				}
+			}
+			state, _ := store.State("a")
+			if state.State != tt.state || state.Incident != nil {
+				t.Errorf("State() = %s with incident %+v, want %s without incident", state.State, state.Incident, tt.state)
+			}
+		})
+	}
+}
+
+func TestIncidentStoreTrackIncident(t *testing.T) {
+	store := NewIncidentStore()
+	c := Check{Name: "a", AlertAfter: 2}
+	store.Track(c, CheckResult{Name: "a", Success: false, Reason: ReasonTimeout, Message: "first"})
+	ev := store.Track(c, CheckResult{Name: "a", Success: false, Reason: ReasonStatus, Message: "second"})
+	if ev.Incident == nil || !ev.Incident.Down || ev.Incident.FailedCount != 2 || ev.Incident.FirstFailure.Message != "first" {
+		t.Fatalf("Track() incident = %+v, want a down incident of 2 failures starting with the first", ev.Incident)
+	}
+	ev = store.Track(c, CheckResult{Name: "a", Success: true})
+	if ev.Type != EventRecovered || ev.Incident.End.IsZero() || ev.Downtime != ev.Incident.End.Sub(ev.Incident.Start) {
+		t.Errorf("Track() of recovery = %+v, want the ended incident and its downtime", ev)
+	}
+}
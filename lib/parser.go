@@ -2,6 +2,8 @@ package lib
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
@@ -14,7 +16,47 @@ type ParsedReporter struct {
 
 // ParsedServer represents server config
 type ParsedServer struct {
-	PollInterval uint32 `yaml:"poll_interval"`
+	PollInterval uint32        `yaml:"poll_interval"`
+	Timeout      time.Duration `yaml:"timeout"`
+	AlertAfter   int           `yaml:"alert_after"`
+	RecoverAfter int           `yaml:"recover_after"`
+	Jitter       time.Duration `yaml:"jitter"`
+	// MaxConcurrency bounds the number of checks run at once, and MaxPerHost the number run
+	// against the same host at once
+	MaxConcurrency int `yaml:"max_concurrency"`
+	MaxPerHost     int `yaml:"max_per_host"`
+	// State configures where check states are saved, so that incidents survive restarts
+	State ParsedState `yaml:"state"`
+	// History configures where every CheckResult is kept, and for how long
+	History ParsedHistory `yaml:"history"`
+	// Listen is the address to serve the status API on, like :8080, or empty to not serve it
+	Listen string `yaml:"listen"`
+	// StatusPage configures the HTML status page served on Listen
+	StatusPage ParsedStatusPage `yaml:"status_page"`
+}
+
+// ParsedState represents the state backend config under server
+type ParsedState struct {
+	// Type is one of file or bolt, or empty to not save check states
+	Type         string        `yaml:"type"`
+	Path         string        `yaml:"path"`
+	SaveInterval time.Duration `yaml:"save_interval"`
+}
+
+// ParsedStatusPage represents the status page config under server
+type ParsedStatusPage struct {
+	Enabled bool   `yaml:"enabled"`
+	Title   string `yaml:"title"`
+	// Logo is the URL of an image shown above the title
+	Logo string `yaml:"logo"`
+}
+
+// ParsedHistory represents the history config under server
+type ParsedHistory struct {
+	// Path is the BoltDB file to keep results in, or empty to not keep them
+	Path          string        `yaml:"path"`
+	RetentionDays int           `yaml:"retention_days"`
+	FlushInterval time.Duration `yaml:"flush_interval"`
 }
 
 // ParsedConfig represents the unmarshalled YAML file
@@ -32,6 +74,70 @@ func ParseYAML(b []byte) (ParsedConfig, error) {
 	if err != nil {
 		return ParsedConfig{}, err
 	}
+	if config.Checks == nil {
+		config.Checks = &[]Check{}
+	}
+	if config.Server.MaxConcurrency < 0 || config.Server.MaxPerHost < 0 {
+		return ParsedConfig{}, errors.New("max_concurrency and max_per_host can't be negative")
+	}
+	if err := validateState(config.Server.State); err != nil {
+		return ParsedConfig{}, err
+	}
+	if err := validateHistory(config.Server.History); err != nil {
+		return ParsedConfig{}, err
+	}
+	if config.Server.StatusPage.Enabled && len(config.Server.Listen) == 0 {
+		return ParsedConfig{}, errors.New("status_page needs listen to be set")
+	}
+	if err := validateDuration("timeout", config.Server.Timeout, time.Millisecond); err != nil {
+		return ParsedConfig{}, err
+	}
+	if err := validateDuration("jitter", config.Server.Jitter, time.Millisecond); err != nil {
+		return ParsedConfig{}, err
+	}
+	if config.Server.Timeout <= 0 {
+		config.Server.Timeout = DefaultTimeout
+	}
+	for i := range *config.Checks {
+		c := &(*config.Checks)[i]
+		if err := validateTimeout(*c); err != nil {
+			return ParsedConfig{}, fmt.Errorf("check %s: %s", c.Name, err.Error())
+		}
+		if c.Timeout <= 0 {
+			c.Timeout = config.Server.Timeout
+		}
+		if err := validateDuration("interval", c.Interval, time.Second); err != nil {
+			return ParsedConfig{}, fmt.Errorf("check %s: %s", c.Name, err.Error())
+		}
+		if c.Interval <= 0 {
+			c.Interval = time.Duration(config.Server.PollInterval) * time.Second
+		}
+		if c.Interval <= 0 {
+			c.Interval = DefaultInterval
+		}
+		if c.AlertAfter == 0 {
+			c.AlertAfter = config.Server.AlertAfter
+		}
+		if c.RecoverAfter == 0 {
+			c.RecoverAfter = config.Server.RecoverAfter
+		}
+		checker, err := GetChecker(c.Type)
+		if err != nil {
+			return ParsedConfig{}, fmt.Errorf("check %s: %s", c.Name, err.Error())
+		}
+		if err := checker.Validate(*c); err != nil {
+			return ParsedConfig{}, fmt.Errorf("check %s: %s", c.Name, err.Error())
+		}
+		if err := validateLatency(*c); err != nil {
+			return ParsedConfig{}, fmt.Errorf("check %s: %s", c.Name, err.Error())
+		}
+		if err := validateRetries(*c); err != nil {
+			return ParsedConfig{}, fmt.Errorf("check %s: %s", c.Name, err.Error())
+		}
+		if err := validateAlerting(*c); err != nil {
+			return ParsedConfig{}, fmt.Errorf("check %s: %s", c.Name, err.Error())
+		}
+	}
 	config.ExportedReporters = []Reporter{}
 	for _, rconfig := range config.Reporters {
 		switch rconfig.Type {
@@ -58,12 +164,7 @@ func ParseYAML(b []byte) (ParsedConfig, error) {
 				}
 				config.ExportedReporters = append(
 					config.ExportedReporters,
-					SlackReporter{
-						ServerConfig: config.Server,
-						SlackChannel: slackChannel,
-						SlackToken:   slackToken,
-						FailedOnly:   failedOnly,
-					})
+					NewSlackReporter(config.Server, slackToken, slackChannel, failedOnly))
 			}
 		case "mailgun":
 			{
@@ -99,14 +200,14 @@ func ParseYAML(b []byte) (ParsedConfig, error) {
 				}
 				config.ExportedReporters = append(
 					config.ExportedReporters,
-					MailgunReporter{
-						ServerConfig:      config.Server,
-						MailgunDomain:     mailgunDomain,
-						MailgunPrivateKey: mailgunPrivateKey,
-						MailgunSender:     mailgunSender,
-						MailgunRecipients: mailgunRecipients,
-						MailgunExclude:    mailgunExclude,
-					},
+					NewMailgunReporter(
+						config.Server,
+						mailgunDomain,
+						mailgunPrivateKey,
+						mailgunSender,
+						mailgunRecipients,
+						mailgunExclude,
+					),
 				)
 			}
 		case "influxdb":
@@ -127,16 +228,31 @@ func ParseYAML(b []byte) (ParsedConfig, error) {
 				if !ok {
 					return ParsedConfig{}, errors.New("can't parse required InfluxDB config influxdb_bucket")
 				}
+				influxDBReporter, err := NewInfluxDBReporter(
+					config.Server,
+					influxDBHost,
+					influxDBToken,
+					influxDBOrg,
+					influxDBBucket,
+				)
+				if err != nil {
+					return ParsedConfig{}, fmt.Errorf("can't create InfluxDB client: %s", err.Error())
+				}
+				config.ExportedReporters = append(config.ExportedReporters, influxDBReporter)
+			}
+		case "prometheus":
+			{
+				prometheusListen, _ := rconfig.Options["prometheus_listen"].(string)
+				if len(prometheusListen) == 0 && len(config.Server.Listen) == 0 {
+					return ParsedConfig{}, errors.New("prometheus_listen is required in Prometheus config when server listen isn't set")
+				}
+				prometheusPath, ok := rconfig.Options["prometheus_path"].(string)
+				if !ok {
+					prometheusPath = "/metrics"
+				}
 				config.ExportedReporters = append(
 					config.ExportedReporters,
-					InfluxDBReporter{
-						ServerConfig:   config.Server,
-						InfluxDBHost:   influxDBHost,
-						InfluxDBToken:  influxDBToken,
-						InfluxDBOrg:    influxDBOrg,
-						InfluxDBBucket: influxDBBucket,
-					},
-				)
+					NewPrometheusReporter(config.Server, prometheusListen, prometheusPath))
 			}
 		}
 	}
@@ -0,0 +1,103 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseCheck parses a config with the given server settings and a single http check with the
+// given extra settings
+func parseCheck(server string, check string) (ParsedConfig, error) {
+	yaml := "server:\n" + server + "\nchecks:\n  - name: a\n    url: http://example.com\n    valid_status: 200\n" + check
+	return ParseYAML([]byte(yaml))
+}
+
+func TestParseYAMLTimeout(t *testing.T) {
+	config, err := parseCheck("  timeout: 5s", "")
+	if err != nil {
+		t.Fatalf("ParseYAML() returned error: %s", err)
+	}
+	if timeout := (*config.Checks)[0].Timeout; timeout != 5*time.Second {
+		t.Errorf("check timeout = %s, want the server timeout of 5s", timeout)
+	}
+	config, err = parseCheck("", "    timeout: 500ms\n")
+	if err != nil {
+		t.Fatalf("ParseYAML() returned error: %s", err)
+	}
+	if timeout := (*config.Checks)[0].Timeout; timeout != 500*time.Millisecond {
+		t.Errorf("check timeout = %s, want 500ms", timeout)
+	}
+	if config.Server.Timeout != DefaultTimeout {
+		t.Errorf("server timeout = %s, want the default of %s", config.Server.Timeout, DefaultTimeout)
+	}
+}
+
+func TestParseYAMLRejectsDurationsWithoutUnit(t *testing.T) {
+	tests := []struct {
+		name   string
+		server string
+		check  string
+	}{
+		{"server timeout", "  timeout: 5", ""},
+		{"check timeout", "", "    timeout: 5\n"},
+		{"max_latency", "", "    max_latency: 500\n"},
+		{"warn_latency", "", "    warn_latency: 500\n"},
+		{"retry_interval", "", "    retries: 1\n    retry_interval: 2\n"},
+		{"interval", "", "    interval: 30\n"},
+		{"sub-second interval", "", "    interval: 500ms\n"},
+		{"jitter", "  jitter: 2", ""},
+		{"state save_interval", "  state:\n    type: file\n    path: state.json\n    save_interval: 30", ""},
+		{"history flush_interval", "  history:\n    path: history.db\n    flush_interval: 30", ""},
+	}
+	for _, tt := range tests {
+		_, err := parseCheck(tt.server, tt.check)
+		if err == nil || !strings.Contains(err.Error(), "must be at least") {
+			t.Errorf("ParseYAML() of a %s without unit returned %v, want it rejected", tt.name, err)
+		}
+	}
+}
+
+func TestParseYAMLInterval(t *testing.T) {
+	config, err := parseCheck("  poll_interval: 30", "")
+	if err != nil {
+		t.Fatalf("ParseYAML() returned error: %s", err)
+	}
+	if interval := (*config.Checks)[0].Interval; interval != 30*time.Second {
+		t.Errorf("check interval = %s, want the server poll_interval of 30s", interval)
+	}
+	config, err = parseCheck("  poll_interval: 30", "    interval: 1m\n")
+	if err != nil {
+		t.Fatalf("ParseYAML() returned error: %s", err)
+	}
+	if interval := (*config.Checks)[0].Interval; interval != time.Minute {
+		t.Errorf("check interval = %s, want 1m", interval)
+	}
+	config, err = parseCheck("", "")
+	if err != nil {
+		t.Fatalf("ParseYAML() returned error: %s", err)
+	}
+	if interval := (*config.Checks)[0].Interval; interval != DefaultInterval {
+		t.Errorf("check interval = %s, want the default of %s", interval, DefaultInterval)
+	}
+}
+
+func TestParseYAMLAlerting(t *testing.T) {
+	config, err := parseCheck("  alert_after: 3\n  recover_after: 2", "")
+	if err != nil {
+		t.Fatalf("ParseYAML() returned error: %s", err)
+	}
+	if c := (*config.Checks)[0]; c.AlertAfter != 3 || c.RecoverAfter != 2 {
+		t.Errorf("check alert_after %d and recover_after %d, want the server 3 and 2", c.AlertAfter, c.RecoverAfter)
+	}
+	config, err = parseCheck("  alert_after: 3", "    alert_after: 5\n")
+	if err != nil {
+		t.Fatalf("ParseYAML() returned error: %s", err)
+	}
+	if c := (*config.Checks)[0]; c.AlertAfter != 5 {
+		t.Errorf("check alert_after %d, want its own 5", c.AlertAfter)
+	}
+	if _, err := parseCheck("", "    recover_after: -1\n"); err == nil {
+		t.Error("ParseYAML() with negative recover_after returned no error")
+	}
+}
@@ -0,0 +1,144 @@
+package lib
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"sync"
+)
+
+// DefaultMaxConcurrency is the number of checks run at once when the server doesn't set max_concurrency
+const DefaultMaxConcurrency = 100
+
+// WorkerPool runs checks on a fixed number of workers, optionally limiting how many checks run
+// against the same host at once
+type WorkerPool struct {
+	size  int
+	jobs  chan poolJob
+	hosts *hostLimiter
+	once  sync.Once
+}
+
+// poolJob is a Check waiting for a worker, along with where to send its result
+type poolJob struct {
+	ctx     context.Context
+	check   Check
+	resChan chan<- CheckResult
+}
+
+// NewWorkerPool returns a WorkerPool with size workers, allowing at most perHost checks against the
+// same host at once. A perHost of zero means no per-host limit.
+func NewWorkerPool(size int, perHost int) *WorkerPool {
+	if size <= 0 {
+		size = DefaultMaxConcurrency
+	}
+	return &WorkerPool{
+		size:  size,
+		jobs:  make(chan poolJob),
+		hosts: newHostLimiter(perHost),
+	}
+}
+
+// Start spawns the workers, which stop once the context is done
+func (p *WorkerPool) Start(ctx context.Context) {
+	p.once.Do(func() {
+		for i := 0; i < p.size; i++ {
+			go p.work(ctx)
+		}
+	})
+}
+
+// work runs jobs until the context is done
+func (p *WorkerPool) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-p.jobs:
+			job.resChan <- runCheck(job.ctx, job.check)
+		}
+	}
+}
+
+// Run waits for the host of the Check and a worker to be free, then runs the Check and returns its
+// result. It returns an error if the context is done before the Check could start.
+func (p *WorkerPool) Run(ctx context.Context, c Check) (CheckResult, error) {
+	host := checkHost(c)
+	if err := p.hosts.acquire(ctx, host); err != nil {
+		return CheckResult{}, err
+	}
+	defer p.hosts.release(host)
+	resChan := make(chan CheckResult, 1)
+	select {
+	case <-ctx.Done():
+		return CheckResult{}, ctx.Err()
+	case p.jobs <- poolJob{ctx: ctx, check: c, resChan: resChan}:
+	}
+	return <-resChan, nil
+}
+
+// checkHost returns the host a Check connects to, used to limit concurrent checks per host
+func checkHost(c Check) string {
+	if len(c.URL) > 0 {
+		if u, err := url.Parse(c.URL); err == nil {
+			return u.Hostname()
+		}
+		return c.URL
+	}
+	if len(c.Address) > 0 {
+		if host, _, err := net.SplitHostPort(c.Address); err == nil {
+			return host
+		}
+		return c.Address
+	}
+	if len(c.Resolver) > 0 {
+		if host, _, err := net.SplitHostPort(c.Resolver); err == nil {
+			return host
+		}
+		return c.Resolver
+	}
+	return c.Record
+}
+
+// hostLimiter bounds the number of concurrent checks per host
+type hostLimiter struct {
+	limit int
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+// newHostLimiter returns a hostLimiter allowing limit checks per host, or any number if limit is zero
+func newHostLimiter(limit int) *hostLimiter {
+	return &hostLimiter{limit: limit, slots: make(map[string]chan struct{})}
+}
+
+// acquire waits for a free slot for the host, or returns an error if the context is done first
+func (hl *hostLimiter) acquire(ctx context.Context, host string) error {
+	if hl.limit <= 0 {
+		return nil
+	}
+	hl.mu.Lock()
+	slots, ok := hl.slots[host]
+	if !ok {
+		slots = make(chan struct{}, hl.limit)
+		hl.slots[host] = slots
+	}
+	hl.mu.Unlock()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case slots <- struct{}{}:
+		return nil
+	}
+}
+
+// release frees a slot acquired for the host
+func (hl *hostLimiter) release(host string) {
+	if hl.limit <= 0 {
+		return
+	}
+	hl.mu.Lock()
+	slots := hl.slots[host]
+	hl.mu.Unlock()
+	<-slots
+}
@@ -0,0 +1,115 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// concurrencyChecker holds every check for a while, recording the most checks that ran at once
+// in total and per host
+type concurrencyChecker struct {
+	mu         sync.Mutex
+	running    int
+	maxRunning int
+	perHost    map[string]int
+	maxPerHost map[string]int
+	holdFor    time.Duration
+}
+
+func (cc *concurrencyChecker) Validate(c Check) error {
+	return nil
+}
+
+func (cc *concurrencyChecker) Check(ctx context.Context, c Check) CheckResult {
+	host := checkHost(c)
+	cc.mu.Lock()
+	cc.running++
+	cc.perHost[host]++
+	if cc.running > cc.maxRunning {
+		cc.maxRunning = cc.running
+	}
+	if cc.perHost[host] > cc.maxPerHost[host] {
+		cc.maxPerHost[host] = cc.perHost[host]
+	}
+	cc.mu.Unlock()
+	time.Sleep(cc.holdFor)
+	cc.mu.Lock()
+	cc.running--
+	cc.perHost[host]--
+	cc.mu.Unlock()
+	return CheckResult{Name: c.Name, Success: true}
+}
+
+// runOnPool runs six checks against each of the given hosts on a WorkerPool of the given limits
+func runOnPool(t *testing.T, size int, perHost int, hosts ...string) *concurrencyChecker {
+	cc := &concurrencyChecker{perHost: map[string]int{}, maxPerHost: map[string]int{}, holdFor: 20 * time.Millisecond}
+	RegisterChecker("concurrency", cc)
+	defer delete(checkers, "concurrency")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool := NewWorkerPool(size, perHost)
+	pool.Start(ctx)
+	var wg sync.WaitGroup
+	for _, host := range hosts {
+		for i := 0; i < 6; i++ {
+			wg.Add(1)
+			go func(c Check) {
+				defer wg.Done()
+				if _, err := pool.Run(ctx, c); err != nil {
+					t.Errorf("Run() returned error: %s", err)
+				}
+			}(Check{Name: fmt.Sprintf("%s-%d", host, i), Type: "concurrency", URL: "http://" + host + "/"})
+		}
+	}
+	wg.Wait()
+	return cc
+}
+
+func TestWorkerPoolSize(t *testing.T) {
+	cc := runOnPool(t, 3, 0, "a.example.com", "b.example.com")
+	if cc.maxRunning != 3 {
+		t.Errorf("at most %d checks ran at once, want 3", cc.maxRunning)
+	}
+}
+
+func TestWorkerPoolPerHost(t *testing.T) {
+	cc := runOnPool(t, 10, 2, "a.example.com", "b.example.com")
+	for _, host := range []string{"a.example.com", "b.example.com"} {
+		if cc.maxPerHost[host] != 2 {
+			t.Errorf("at most %d checks ran at once against %s, want 2", cc.maxPerHost[host], host)
+		}
+	}
+	if cc.maxRunning != 4 {
+		t.Errorf("at most %d checks ran at once, want 4", cc.maxRunning)
+	}
+}
+
+func TestWorkerPoolRunCancelled(t *testing.T) {
+	pool := NewWorkerPool(1, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// The pool isn't started, so the Check can only give up once the context is done
+	if _, err := pool.Run(ctx, Check{Name: "a", URL: "http://example.com"}); err == nil {
+		t.Error("Run() with a cancelled context returned no error")
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	tests := []struct {
+		check Check
+		host  string
+	}{
+		{Check{URL: "https://example.com:8443/health"}, "example.com"},
+		{Check{Address: "db.internal:5432"}, "db.internal"},
+		{Check{Record: "example.com", Resolver: "1.1.1.1:53"}, "1.1.1.1"},
+		{Check{Record: "example.com"}, "example.com"},
+	}
+	for _, tt := range tests {
+		if host := checkHost(tt.check); host != tt.host {
+			t.Errorf("checkHost(%+v) = %q, want %q", tt.check, host, tt.host)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package lib
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ReportFormats are the formats a Report can be written in
+var ReportFormats = []string{"markdown", "html", "csv", "json"}
+
+// CheckReport is the uptime, incidents and latency of a check over the period of a Report
+type CheckReport struct {
+	Name string
+	// Results is the number of results kept for the check in the period
+	Results int
+	// Monitored is the part of the period since the first result kept for the check
+	Monitored time.Duration
+	// Uptime is the percent of Monitored that the check wasn't down for
+	Uptime    float64
+	Incidents int
+	Downtime  time.Duration
+	// MTTR is the mean time to recover from an incident, and MTBF the mean time between them
+	MTTR time.Duration
+	MTBF time.Duration
+	// Latency percentiles of successful results
+	P50 time.Duration
+	P95 time.Duration
+	P99 time.Duration
+}
+
+// Report is the uptime, incidents and latency of every check over a period
+type Report struct {
+	Since  time.Time
+	Until  time.Time
+	Checks []CheckReport
+}
+
+// NewReport computes the Report of the given checks between since and until from the HistoryStore.
+// Downtime counts every incident of a check that went down, from its first failure until it
+// recovered, for the part of it within the period.
+func NewReport(hs *HistoryStore, cs []Check, since time.Time, until time.Time) (Report, error) {
+	if !since.Before(until) {
+		return Report{}, fmt.Errorf("since (%s) must be before until (%s)", since.Format(time.RFC3339), until.Format(time.RFC3339))
+	}
+	report := Report{Since: since, Until: until, Checks: make([]CheckReport, 0, len(cs))}
+	for _, c := range cs {
+		records, err := hs.Results(c.Name, since)
+		if err != nil {
+			return Report{}, err
+		}
+		// Incidents that started before the period may still overlap it
+		incidents, err := hs.Incidents(c.Name, time.Time{})
+		if err != nil {
+			return Report{}, err
+		}
+		end := 0
+		for end < len(records) && !records[end].Time.After(until) {
+			end++
+		}
+		report.Checks = append(report.Checks, newCheckReport(c.Name, records[:end], incidents, until))
+	}
+	return report, nil
+}
+
+// ParseReportTime parses the start or end of a report period, given as a date like 2006-01-02 in
+// local time, a time like 2006-01-02T15:04:05Z, or a duration before now like 24h or 30d
+func ParseReportTime(s string, now time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if d, err := ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %s, expected a date like 2006-01-02, a time like 2006-01-02T15:04:05Z or a duration like 30d", s)
+}
+
+// DailyReports computes the uptime and incidents of the named check for each of the last given
+// number of days in local time, oldest first, with the last one covering today until now. They
+// are computed from the daily summaries of the HistoryStore, so they have no latency percentiles.
+func DailyReports(hs *HistoryStore, name string, days int, now time.Time) ([]CheckReport, error) {
+	now = now.Local()
+	first := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, 1-days)
+	summaries, err := hs.Days(name, first)
+	if err != nil {
+		return nil, err
+	}
+	incidents, err := hs.Incidents(name, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	byDay := make(map[string]HistoryDay, len(summaries))
+	for _, day := range summaries {
+		byDay[day.Day] = day
+	}
+	reports := make([]CheckReport, days)
+	for i := range reports {
+		start := first.AddDate(0, 0, i)
+		until := start.AddDate(0, 0, 1)
+		if until.After(now) {
+			until = now
+		}
+		day, ok := byDay[start.Format(historyDayFormat)]
+		if !ok {
+			reports[i] = CheckReport{Name: name}
+			continue
+		}
+		reports[i] = newUptimeReport(name, day.Results, day.First, incidents, until)
+	}
+	return reports, nil
+}
+
+// newCheckReport computes the CheckReport of the named check from its results in a period ending
+// at until, and its incidents, of which only the parts within the period count
+func newCheckReport(name string, records []HistoryRecord, incidents []Incident, until time.Time) CheckReport {
+	if len(records) == 0 {
+		return CheckReport{Name: name}
+	}
+	latencies := []time.Duration{}
+	for _, record := range records {
+		if record.Result.Success {
+			latencies = append(latencies, record.Result.Latency)
+		}
+	}
+	cr := newUptimeReport(name, len(records), records[0].Time, incidents, until)
+	cr.P50 = Percentile(latencies, 50)
+	cr.P95 = Percentile(latencies, 95)
+	cr.P99 = Percentile(latencies, 99)
+	return cr
+}
+
+// newUptimeReport computes the uptime and incidents of the named check from the number of its
+// results in a period ending at until, the first of which was recorded at monitoredSince
+func newUptimeReport(name string, results int, monitoredSince time.Time, incidents []Incident, until time.Time) CheckReport {
+	cr := CheckReport{Name: name, Results: results, Monitored: until.Sub(monitoredSince)}
+	for _, incident := range incidents {
+		start, end := incident.Start, incident.End
+		if end.IsZero() || end.After(until) {
+			end = until
+		}
+		if start.Before(monitoredSince) {
+			start = monitoredSince
+		}
+		if !end.After(start) {
+			continue
+		}
+		cr.Incidents++
+		cr.Downtime += end.Sub(start)
+	}
+	cr.Uptime = 100
+	if cr.Monitored > 0 {
+		cr.Uptime = 100 * float64(cr.Monitored-cr.Downtime) / float64(cr.Monitored)
+	}
+	if cr.Incidents > 0 {
+		cr.MTTR = cr.Downtime / time.Duration(cr.Incidents)
+		cr.MTBF = (cr.Monitored - cr.Downtime) / time.Duration(cr.Incidents)
+	}
+	return cr
+}
+
+// Write writes the Report to w in the given format, one of ReportFormats
+func (r Report) Write(w io.Writer, format string) error {
+	switch format {
+	case "markdown":
+		{
+			return r.writeMarkdown(w)
+		}
+	case "html":
+		{
+			return reportTemplate.Execute(w, r)
+		}
+	case "csv":
+		{
+			return r.writeCSV(w)
+		}
+	case "json":
+		{
+			return r.writeJSON(w)
+		}
+	}
+	return fmt.Errorf("unknown report format %s, expected one of %v", format, ReportFormats)
+}
+
+// writeMarkdown writes the Report as a Markdown table
+func (r Report) writeMarkdown(w io.Writer) error {
+	fmt.Fprintf(w, "## Uptime report from %s to %s\n\n", r.Since.Format(time.RFC1123), r.Until.Format(time.RFC1123))
+	fmt.Fprintln(w, "| Check | Uptime | Incidents | Downtime | MTTR | MTBF | p50 | p95 | p99 |")
+	fmt.Fprintln(w, "|---|---:|---:|---:|---:|---:|---:|---:|---:|")
+	for _, cr := range r.Checks {
+		_, err := fmt.Fprintf(w, "| %s | %s | %d | %s | %s | %s | %s | %s | %s |\n",
+			cr.Name,
+			cr.UptimeString(),
+			cr.Incidents,
+			reportDuration(cr.Downtime, cr.Results > 0),
+			reportDuration(cr.MTTR, cr.Incidents > 0),
+			reportDuration(cr.MTBF, cr.Incidents > 0),
+			reportLatency(cr.P50, cr.Results > 0),
+			reportLatency(cr.P95, cr.Results > 0),
+			reportLatency(cr.P99, cr.Results > 0))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeCSV writes the Report as CSV, with durations in seconds and latencies in milliseconds
+func (r Report) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"check", "results", "uptime_percent", "incidents", "downtime_seconds", "mttr_seconds", "mtbf_seconds", "p50_ms", "p95_ms", "p99_ms"})
+	for _, cr := range r.Checks {
+		cw.Write([]string{
+			cr.Name,
+			strconv.Itoa(cr.Results),
+			strconv.FormatFloat(cr.Uptime, 'f', 3, 64),
+			strconv.Itoa(cr.Incidents),
+			strconv.FormatFloat(cr.Downtime.Seconds(), 'f', 0, 64),
+			strconv.FormatFloat(cr.MTTR.Seconds(), 'f', 0, 64),
+			strconv.FormatFloat(cr.MTBF.Seconds(), 'f', 0, 64),
+			strconv.FormatInt(int64(cr.P50/time.Millisecond), 10),
+			strconv.FormatInt(int64(cr.P95/time.Millisecond), 10),
+			strconv.FormatInt(int64(cr.P99/time.Millisecond), 10),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeJSON writes the Report as JSON, with durations in seconds and latencies in milliseconds
+func (r Report) writeJSON(w io.Writer) error {
+	type checkJSON struct {
+		Name            string  `json:"name"`
+		Results         int     `json:"results"`
+		UptimePercent   float64 `json:"uptime_percent"`
+		Incidents       int     `json:"incidents"`
+		DowntimeSeconds float64 `json:"downtime_seconds"`
+		MTTRSeconds     float64 `json:"mttr_seconds"`
+		MTBFSeconds     float64 `json:"mtbf_seconds"`
+		P50Ms           int64   `json:"p50_ms"`
+		P95Ms           int64   `json:"p95_ms"`
+		P99Ms           int64   `json:"p99_ms"`
+	}
+	checks := make([]checkJSON, len(r.Checks))
+	for i, cr := range r.Checks {
+		checks[i] = checkJSON{
+			Name:            cr.Name,
+			Results:         cr.Results,
+			UptimePercent:   cr.Uptime,
+			Incidents:       cr.Incidents,
+			DowntimeSeconds: cr.Downtime.Seconds(),
+			MTTRSeconds:     cr.MTTR.Seconds(),
+			MTBFSeconds:     cr.MTBF.Seconds(),
+			P50Ms:           int64(cr.P50 / time.Millisecond),
+			P95Ms:           int64(cr.P95 / time.Millisecond),
+			P99Ms:           int64(cr.P99 / time.Millisecond),
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Since  time.Time   `json:"since"`
+		Until  time.Time   `json:"until"`
+		Checks []checkJSON `json:"checks"`
+	}{r.Since, r.Until, checks})
+}
+
+// UptimeString returns the Uptime as a percent with three decimals, or n/a without results
+func (cr CheckReport) UptimeString() string {
+	if cr.Results == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.3f%%", cr.Uptime)
+}
+
+// reportDuration formats a duration to the second, or a dash when it isn't known
+func reportDuration(d time.Duration, known bool) string {
+	if !known {
+		return "-"
+	}
+	return d.Round(time.Second).String()
+}
+
+// reportLatency formats a latency to the millisecond, or a dash when it isn't known
+func reportLatency(d time.Duration, known bool) string {
+	if !known {
+		return "-"
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"duration": reportDuration,
+	"latency":  reportLatency,
+	"time":     func(t time.Time) string { return t.Format(time.RFC1123) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Uptime report</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+</style>
+</head>
+<body>
+<h2>Uptime report from {{time .Since}} to {{time .Until}}</h2>
+<table>
+<tr><th>Check</th><th>Uptime</th><th>Incidents</th><th>Downtime</th><th>MTTR</th><th>MTBF</th><th>p50</th><th>p95</th><th>p99</th></tr>
+{{- range .Checks}}
+<tr><td>{{.Name}}</td><td>{{.UptimeString}}</td><td>{{.Incidents}}</td><td>{{duration .Downtime (gt .Results 0)}}</td><td>{{duration .MTTR (gt .Incidents 0)}}</td><td>{{duration .MTBF (gt .Incidents 0)}}</td><td>{{latency .P50 (gt .Results 0)}}</td><td>{{latency .P95 (gt .Results 0)}}</td><td>{{latency .P99 (gt .Results 0)}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
@@ -0,0 +1,168 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewCheckReport(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := start.Add(100 * time.Hour)
+	records := []HistoryRecord{
+		{Time: start, Result: CheckResult{Success: true, Latency: 10 * time.Millisecond}},
+		{Time: start.Add(time.Hour), Result: CheckResult{Success: false}},
+		{Time: start.Add(2 * time.Hour), Result: CheckResult{Success: true, Latency: 30 * time.Millisecond}},
+		{Time: start.Add(3 * time.Hour), Result: CheckResult{Success: true, Latency: 20 * time.Millisecond}},
+	}
+	incidents := []Incident{
+		// Started before the first result, so only counts from it
+		{Start: start.Add(-5 * time.Hour), End: start.Add(2 * time.Hour)},
+		// Still ongoing, so counts until the end of the period
+		{Start: start.Add(97 * time.Hour)},
+		// Over before the first result
+		{Start: start.Add(-10 * time.Hour), End: start.Add(-9 * time.Hour)},
+	}
+	cr := newCheckReport("a", records, incidents, until)
+	if cr.Results != 4 || cr.Monitored != 100*time.Hour {
+		t.Errorf("newCheckReport() = %d results over %s, want 4 over 100h", cr.Results, cr.Monitored)
+	}
+	if cr.Incidents != 2 || cr.Downtime != 5*time.Hour {
+		t.Errorf("newCheckReport() = %d incidents for %s, want 2 for 5h", cr.Incidents, cr.Downtime)
+	}
+	if cr.Uptime != 95 || cr.MTTR != 150*time.Minute || cr.MTBF != 47*time.Hour+30*time.Minute {
+		t.Errorf("newCheckReport() uptime %g, MTTR %s, MTBF %s, want 95, 2h30m and 47h30m", cr.Uptime, cr.MTTR, cr.MTBF)
+	}
+	if cr.P50 != 20*time.Millisecond || cr.P99 != 30*time.Millisecond {
+		t.Errorf("newCheckReport() p50 %s, p99 %s, want 20ms and 30ms", cr.P50, cr.P99)
+	}
+	if cr := newCheckReport("a", nil, incidents, until); cr.Results != 0 || cr.UptimeString() != "n/a" {
+		t.Errorf("newCheckReport() without results = %+v, want an unknown uptime", cr)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	latencies := []time.Duration{5, 1, 4, 2, 3, 6, 8, 7, 10, 9}
+	tests := []struct {
+		percent  float64
+		expected time.Duration
+	}{
+		{0, 1},
+		{10, 1},
+		{50, 5},
+		{95, 10},
+		{99, 10},
+		{100, 10},
+	}
+	for _, tt := range tests {
+		if p := Percentile(latencies, tt.percent); p != tt.expected {
+			t.Errorf("Percentile(%g) = %d, want %d", tt.percent, p, tt.expected)
+		}
+	}
+	if p := Percentile(nil, 50); p != 0 {
+		t.Errorf("Percentile() of no latencies = %d, want 0", p)
+	}
+	if latencies[0] != 5 {
+		t.Error("Percentile() sorted the latencies in place")
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected time.Duration
+		valid    bool
+	}{
+		{"30d", 30 * 24 * time.Hour, true},
+		{"24h", 24 * time.Hour, true},
+		{"90m", 90 * time.Minute, true},
+		{"-1d", 0, false},
+		{"1.5d", 0, false},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		d, err := ParseDuration(tt.s)
+		if (err == nil) != tt.valid || d != tt.expected {
+			t.Errorf("ParseDuration(%q) = %s, %v, want %s, valid %v", tt.s, d, err, tt.expected, tt.valid)
+		}
+	}
+}
+
+func TestParseReportTime(t *testing.T) {
+	now := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		s        string
+		expected time.Time
+	}{
+		{"7d", now.AddDate(0, 0, -7)},
+		{"2h", now.Add(-2 * time.Hour)},
+		{"2020-02-01", time.Date(2020, 2, 1, 0, 0, 0, 0, time.Local)},
+		{"2020-02-01T10:30:00Z", time.Date(2020, 2, 1, 10, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if parsed, err := ParseReportTime(tt.s, now); err != nil || !parsed.Equal(tt.expected) {
+			t.Errorf("ParseReportTime(%q) = %s, %v, want %s", tt.s, parsed, err, tt.expected)
+		}
+	}
+	if _, err := ParseReportTime("last week", now); err == nil {
+		t.Error("ParseReportTime(\"last week\") returned no error")
+	}
+}
+
+func TestNewReport(t *testing.T) {
+	hs, dir := newTestHistoryStore(t)
+	defer os.RemoveAll(dir)
+	now := time.Now()
+	hs.results = []HistoryRecord{
+		{Time: now.Add(-3 * time.Hour), Result: CheckResult{Name: "a", Success: true}},
+		{Time: now.Add(-2 * time.Hour), Result: CheckResult{Name: "a", Success: true}},
+		{Time: now.Add(-time.Hour), Result: CheckResult{Name: "a", Success: true}},
+	}
+	hs.Flush()
+	report, err := NewReport(hs, []Check{{Name: "a"}, {Name: "b"}}, now.Add(-150*time.Minute), now.Add(-90*time.Minute))
+	if err != nil {
+		t.Fatalf("NewReport() returned error: %s", err)
+	}
+	if len(report.Checks) != 2 || report.Checks[0].Results != 1 || report.Checks[1].Results != 0 {
+		t.Errorf("NewReport() = %+v, want the one result of a within the period and none of b", report.Checks)
+	}
+	if _, err := NewReport(hs, []Check{{Name: "a"}}, now, now.Add(-time.Hour)); err == nil {
+		t.Error("NewReport() with since after until returned no error")
+	}
+}
+
+func TestReportWrite(t *testing.T) {
+	since := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	report := Report{Since: since, Until: since.AddDate(0, 0, 30), Checks: []CheckReport{
+		{Name: "api", Results: 10, Uptime: 99.5, Incidents: 1, Downtime: time.Hour, P50: 120 * time.Millisecond},
+		{Name: "new"},
+	}}
+	for _, format := range ReportFormats {
+		var b bytes.Buffer
+		if err := report.Write(&b, format); err != nil {
+			t.Errorf("Write(%s) returned error: %s", format, err)
+		}
+		if !strings.Contains(b.String(), "api") {
+			t.Errorf("Write(%s) = %q, want the api check", format, b.String())
+		}
+	}
+	var b bytes.Buffer
+	report.Write(&b, "markdown")
+	if !strings.Contains(b.String(), "| api | 99.500% | 1 | 1h0m0s |") || !strings.Contains(b.String(), "| new | n/a | 0 | - |") {
+		t.Errorf("Write(markdown) = %q, want a row per check", b.String())
+	}
+	b.Reset()
+	report.Write(&b, "json")
+	var parsed struct {
+		Checks []map[string]interface{} `json:"checks"`
+	}
+	if err := json.Unmarshal(b.Bytes(), &parsed); err != nil || parsed.Checks[0]["downtime_seconds"] != 3600.0 || parsed.Checks[0]["p50_ms"] != 120.0 {
+		t.Errorf("Write(json) = %s, want durations in seconds and latencies in milliseconds", b.String())
+	}
+	if err := report.Write(&b, "pdf"); err == nil {
+		t.Error("Write(pdf) returned no error")
+	}
+}
@@ -3,7 +3,7 @@ package lib
 import (
 	"context"
 	"fmt"
-	"math"
+	"net/http"
 	"strconv"
 	"time"
 
@@ -12,11 +12,13 @@ import (
 	"github.com/influxdata/influxdb-client-go"
 	"github.com/mailgun/mailgun-go"
 	"github.com/nlopes/slack"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-// Reporter consumes a CheckResult to flush into some predefined sink
+// Reporter consumes the Event of a CheckResult to flush into some predefined sink
 type Reporter interface {
-	Report(CheckResult, *map[string]int) error
+	Report(context.Context, Event) error
 }
 
 // LogReporter implements Reporter by writing to log
@@ -25,15 +27,20 @@ type LogReporter struct {
 }
 
 // Report writes to log
-func (lr LogReporter) Report(c CheckResult, dc *map[string]int) error {
-	switch c.Success {
-	case true:
+func (lr LogReporter) Report(ctx context.Context, ev Event) error {
+	c := ev.Result
+	switch c.Status() {
+	case "up":
 		{
 			log.Infof("[%s] Check successful. [%s]", c.Name, c.Latency)
 		}
-	case false:
+	case "degraded":
 		{
-			log.Debugf("[%s] Check failed. [%s] Reason: %s", c.Name, c.Message, c.Latency)
+			log.Warnf("[%s] Check degraded. [%s] Reason: %s", c.Name, c.Latency, c.Message)
+		}
+	case "down":
+		{
+			log.Debugf("[%s] Check failed after %d attempt(s). [%s] Reason: %s", c.Name, c.Attempts, c.Latency, c.Message)
 		}
 	}
 	return nil
@@ -45,99 +52,138 @@ type SlackReporter struct {
 	SlackToken   string
 	SlackChannel string
 	FailedOnly   bool
+	client       *slack.Client
+}
+
+// NewSlackReporter returns a SlackReporter with a client to reuse across reports
+func NewSlackReporter(sc ParsedServer, slackToken string, slackChannel string, failedOnly bool) SlackReporter {
+	return SlackReporter{
+		ServerConfig: sc,
+		SlackToken:   slackToken,
+		SlackChannel: slackChannel,
+		FailedOnly:   failedOnly,
+		client:       slack.New(slackToken),
+	}
 }
 
 // Report sends an update to Slack
-func (sr SlackReporter) Report(c CheckResult, dc *map[string]int) error {
-	client := slack.New(sr.SlackToken)
-	switch c.Success {
-	case true:
+func (sr SlackReporter) Report(ctx context.Context, ev Event) error {
+	c := ev.Result
+	client := sr.client
+	if client == nil {
+		client = slack.New(sr.SlackToken)
+	}
+	var attachment slack.Attachment
+	switch ev.Type {
+	case EventWentDown:
 		{
-			if sr.FailedOnly == false && (*dc)[c.Name] == 0 {
-				attachment := slack.Attachment{
-					Color:  "#36a64f",
-					Footer: "bantay uptime check",
-					Text:   fmt.Sprintf("%s check succeeded.", c.Name),
-				}
-				_, _, err := client.PostMessage(
-					sr.SlackChannel,
-					slack.MsgOptionAsUser(false),
-					slack.MsgOptionUsername("bantay"),
-					slack.MsgOptionAttachments(attachment),
-				)
-				if err != nil {
-					return err
-				}
-			} else if (*dc)[c.Name] != 0 {
-				attachment := slack.Attachment{
-					Color:  "#36a64f",
-					Footer: "bantay uptime check",
-					Text:   fmt.Sprintf("%s is back up.", c.Name),
-					Fields: []slack.AttachmentField{
-						slack.AttachmentField{
-							Title: "Failed Check Count",
-							Value: strconv.Itoa((*dc)[c.Name]),
-						},
-						slack.AttachmentField{
-							Title: "Total Downtime",
-							Value: durafmt.Parse(time.Duration(math.Ceil((float64((*dc)[c.Name]) * float64(sr.ServerConfig.PollInterval)))) * time.Second).String(),
-						},
+			attachment = slack.Attachment{
+				Color: "#bd2f2f",
+				Fields: []slack.AttachmentField{
+					slack.AttachmentField{
+						Title: "Reason",
+						Value: c.Message,
+					},
+					slack.AttachmentField{
+						Title: "Failed Check Count",
+						Value: strconv.Itoa(ev.Incident.FailedCount),
+					},
+					slack.AttachmentField{
+						Title: "Attempts",
+						Value: strconv.Itoa(c.Attempts),
 					},
-				}
-				_, _, err := client.PostMessage(
-					sr.SlackChannel,
-					slack.MsgOptionAsUser(false),
-					slack.MsgOptionUsername("bantay"),
-					slack.MsgOptionAttachments(attachment),
-				)
-				if err != nil {
-					return err
-				}
+				},
+				Footer: "bantay uptime check",
+				Text:   fmt.Sprintf("%s went down.", c.Name),
 			}
 		}
-	case false:
+	case EventStillDown:
 		{
-			var attachment slack.Attachment
-			if (*dc)[c.Name] == 0 {
-				attachment = slack.Attachment{
-					Color: "#bd2f2f",
-					Fields: []slack.AttachmentField{
-						slack.AttachmentField{
-							Title: "Reason",
-							Value: c.Message,
-						},
+			attachment = slack.Attachment{
+				Color: "#bd2f2f",
+				Fields: []slack.AttachmentField{
+					slack.AttachmentField{
+						Title: "Reason",
+						Value: c.Message,
 					},
-					Footer: "bantay uptime check",
-					Text:   fmt.Sprintf("%s went down.", c.Name),
-				}
-			} else {
-				attachment = slack.Attachment{
-					Color: "#bd2f2f",
-					Fields: []slack.AttachmentField{
-						slack.AttachmentField{
-							Title: "Reason",
-							Value: c.Message,
-						},
-						slack.AttachmentField{
-							Title: "Failed Check Count",
-							Value: strconv.Itoa((*dc)[c.Name] + 1),
-						},
+					slack.AttachmentField{
+						Title: "Failed Check Count",
+						Value: strconv.Itoa(ev.Incident.FailedCount),
 					},
-					Footer: "bantay uptime check",
-					Text:   fmt.Sprintf("%s is still down.", c.Name),
-				}
+					slack.AttachmentField{
+						Title: "Attempts",
+						Value: strconv.Itoa(c.Attempts),
+					},
+					slack.AttachmentField{
+						Title: "Down For",
+						Value: durafmt.Parse(ev.Downtime).String(),
+					},
+				},
+				Footer: "bantay uptime check",
+				Text:   fmt.Sprintf("%s is still down.", c.Name),
 			}
-			_, _, err := client.PostMessage(
-				sr.SlackChannel,
-				slack.MsgOptionAsUser(false),
-				slack.MsgOptionUsername("bantay"),
-				slack.MsgOptionAttachments(attachment),
-			)
-			if err != nil {
-				return err
+		}
+	case EventRecovered:
+		{
+			attachment = slack.Attachment{
+				Color:  "#36a64f",
+				Footer: "bantay uptime check",
+				Text:   fmt.Sprintf("%s is back up.", c.Name),
+				Fields: []slack.AttachmentField{
+					slack.AttachmentField{
+						Title: "Failed Check Count",
+						Value: strconv.Itoa(ev.Incident.FailedCount),
+					},
+					slack.AttachmentField{
+						Title: "First Failure Reason",
+						Value: ev.Incident.FirstFailure.Message,
+					},
+					slack.AttachmentField{
+						Title: "Total Downtime",
+						Value: durafmt.Parse(ev.Downtime).String(),
+					},
+				},
+			}
+		}
+	case EventDegraded:
+		{
+			attachment = slack.Attachment{
+				Color: "#daa038",
+				Fields: []slack.AttachmentField{
+					slack.AttachmentField{
+						Title: "Reason",
+						Value: c.Message,
+					},
+					slack.AttachmentField{
+						Title: "Latency",
+						Value: c.Latency.String(),
+					},
+				},
+				Footer: "bantay uptime check",
+				Text:   fmt.Sprintf("%s is degraded.", c.Name),
+			}
+		}
+	default:
+		{
+			if sr.FailedOnly == true || c.Status() != "up" || ev.Incident != nil {
+				return nil
+			}
+			attachment = slack.Attachment{
+				Color:  "#36a64f",
+				Footer: "bantay uptime check",
+				Text:   fmt.Sprintf("%s check succeeded.", c.Name),
 			}
 		}
-
+	}
+	_, _, err := client.PostMessageContext(
+		ctx,
+		sr.SlackChannel,
+		slack.MsgOptionAsUser(false),
+		slack.MsgOptionUsername("bantay"),
+		slack.MsgOptionAttachments(attachment),
+	)
+	if err != nil {
+		return err
 	}
 	return nil
 }
@@ -150,11 +196,29 @@ type MailgunReporter struct {
 	MailgunRecipients []string
 	MailgunSender     string
 	MailgunExclude    []string
+	mg                *mailgun.MailgunImpl
+}
+
+// NewMailgunReporter returns a MailgunReporter with a client to reuse across reports
+func NewMailgunReporter(sc ParsedServer, domain string, privateKey string, sender string, recipients []string, exclude []string) MailgunReporter {
+	return MailgunReporter{
+		ServerConfig:      sc,
+		MailgunDomain:     domain,
+		MailgunPrivateKey: privateKey,
+		MailgunSender:     sender,
+		MailgunRecipients: recipients,
+		MailgunExclude:    exclude,
+		mg:                mailgun.NewMailgun(domain, privateKey),
+	}
 }
 
 // Report sends an email via Mailgun
-func (mr MailgunReporter) Report(c CheckResult, dc *map[string]int) error {
-	mg := mailgun.NewMailgun(mr.MailgunDomain, mr.MailgunPrivateKey)
+func (mr MailgunReporter) Report(ctx context.Context, ev Event) error {
+	c := ev.Result
+	mg := mr.mg
+	if mg == nil {
+		mg = mailgun.NewMailgun(mr.MailgunDomain, mr.MailgunPrivateKey)
+	}
 	var (
 		body    string
 		subject string
@@ -164,21 +228,23 @@ func (mr MailgunReporter) Report(c CheckResult, dc *map[string]int) error {
 			return nil
 		}
 	}
-	if c.Success == true && (*dc)[c.Name] != 0 {
+	switch ev.Type {
+	case EventRecovered:
 		body = fmt.Sprintf(
-			"%s is back up. Estimated total downtime: %s.",
+			"%s is back up. Total downtime: %s.",
 			c.Name,
-			durafmt.Parse(time.Duration(math.Ceil((float64((*dc)[c.Name])*float64(mr.ServerConfig.PollInterval))))*time.Second).String(),
+			durafmt.Parse(ev.Downtime).String(),
 		)
 		subject = fmt.Sprintf(
 			"[%s] %s is back up",
 			time.Now().Format("01/02/06 15:04:05 MST"),
 			c.Name,
 		)
-	} else if c.Success == false && (*dc)[c.Name] == 0 {
+	case EventWentDown:
 		body = fmt.Sprintf(
-			"%s went down. Reason: %s",
+			"%s went down after %d attempt(s). Reason: %s",
 			c.Name,
+			c.Attempts,
 			c.Message,
 		)
 		subject = fmt.Sprintf(
@@ -186,9 +252,11 @@ func (mr MailgunReporter) Report(c CheckResult, dc *map[string]int) error {
 			time.Now().Format("01/02/06 15:04:05 MST"),
 			c.Name,
 		)
+	default:
+		return nil
 	}
 	message := mg.NewMessage(mr.MailgunSender, subject, body, mr.MailgunRecipients...)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
 	defer cancel()
 
 	_, _, err := mg.Send(ctx, message)
@@ -200,23 +268,45 @@ func (mr MailgunReporter) Report(c CheckResult, dc *map[string]int) error {
 	return nil
 }
 
-// InfluxDBReporter writes up/down status and latency to InfluxDB
+// InfluxDBReporter writes up/degraded/down status and latency to InfluxDB
 type InfluxDBReporter struct {
 	ServerConfig   ParsedServer
 	InfluxDBHost   string
 	InfluxDBToken  string
 	InfluxDBBucket string
 	InfluxDBOrg    string
+	influx         *influxdb.Client
 }
 
-// Report puts a metric update to InfluxDB
-func (ir InfluxDBReporter) Report(c CheckResult, dc *map[string]int) error {
-	influx, err := influxdb.New(
-		ir.InfluxDBHost,
-		ir.InfluxDBToken,
-	)
+// NewInfluxDBReporter returns an InfluxDBReporter with a client to reuse across reports
+func NewInfluxDBReporter(sc ParsedServer, host string, token string, org string, bucket string) (InfluxDBReporter, error) {
+	influx, err := influxdb.New(host, token)
 	if err != nil {
-		return err
+		return InfluxDBReporter{}, err
+	}
+	return InfluxDBReporter{
+		ServerConfig:   sc,
+		InfluxDBHost:   host,
+		InfluxDBToken:  token,
+		InfluxDBOrg:    org,
+		InfluxDBBucket: bucket,
+		influx:         influx,
+	}, nil
+}
+
+// Report puts a metric update to InfluxDB
+func (ir InfluxDBReporter) Report(ctx context.Context, ev Event) error {
+	c := ev.Result
+	influx := ir.influx
+	if influx == nil {
+		var err error
+		influx, err = influxdb.New(
+			ir.InfluxDBHost,
+			ir.InfluxDBToken,
+		)
+		if err != nil {
+			return err
+		}
 	}
 	var up int8
 	if c.Success == true {
@@ -224,16 +314,108 @@ func (ir InfluxDBReporter) Report(c CheckResult, dc *map[string]int) error {
 	} else {
 		up = 0
 	}
+	fields := map[string]interface{}{"latency": int64(c.Latency / time.Millisecond), "up": up, "status": c.Status(), "attempts": c.Attempts}
+	if !c.CertExpiry.IsZero() {
+		fields["cert_days_remaining"] = c.CertDaysRemaining()
+	}
+	if c.Timing != nil {
+		fields["latency_dns"] = int64(c.Timing.DNSLookup / time.Millisecond)
+		fields["latency_connect"] = int64(c.Timing.TCPConnect / time.Millisecond)
+		fields["latency_tls"] = int64(c.Timing.TLSHandshake / time.Millisecond)
+		fields["latency_ttfb"] = int64(c.Timing.FirstByte / time.Millisecond)
+		fields["latency_transfer"] = int64(c.Timing.Transfer / time.Millisecond)
+	}
 	metrics := []influxdb.Metric{
 		influxdb.NewRowMetric(
-			map[string]interface{}{"latency": int64(c.Latency / time.Millisecond), "up": up},
+			fields,
 			"check-result",
 			map[string]string{"check-name": c.Name},
 			time.Now(),
 		),
 	}
-	if _, err := influx.Write(context.Background(), ir.InfluxDBBucket, ir.InfluxDBOrg, metrics...); err != nil {
+	if _, err := influx.Write(ctx, ir.InfluxDBBucket, ir.InfluxDBOrg, metrics...); err != nil {
 		return err
 	}
 	return nil
 }
+
+// PrometheusReporter exposes the status, latency, failures and certificate expiry of every check
+// as Prometheus metrics, served on PrometheusListen or, when that is empty, the server listener
+type PrometheusReporter struct {
+	ServerConfig     ParsedServer
+	PrometheusListen string
+	PrometheusPath   string
+	registry         *prometheus.Registry
+	up               *prometheus.GaugeVec
+	latency          *prometheus.HistogramVec
+	failures         *prometheus.CounterVec
+	certExpiry       *prometheus.GaugeVec
+}
+
+// NewPrometheusReporter returns a PrometheusReporter with its own registry of metrics
+func NewPrometheusReporter(sc ParsedServer, listen string, path string) PrometheusReporter {
+	pr := PrometheusReporter{
+		ServerConfig:     sc,
+		PrometheusListen: listen,
+		PrometheusPath:   path,
+		registry:         prometheus.NewRegistry(),
+		up: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "bantay_check_up",
+			Help: "Whether the last result of the check succeeded (1) or failed (0).",
+		}, []string{"check"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "bantay_check_latency_seconds",
+			Help:    "Latency of successful check results, in total and, for http checks, by phase.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"check", "phase"}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "bantay_check_failures_total",
+			Help: "Number of failed check results, by reason.",
+		}, []string{"check", "reason"}),
+		certExpiry: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "bantay_check_cert_expiry_days",
+			Help: "Days until the earliest expiry in the peer certificate chain of the check.",
+		}, []string{"check"}),
+	}
+	pr.registry.MustRegister(pr.up, pr.latency, pr.failures, pr.certExpiry)
+	return pr
+}
+
+// Report updates the metrics of the check
+func (pr PrometheusReporter) Report(ctx context.Context, ev Event) error {
+	c := ev.Result
+	if c.Success {
+		pr.up.WithLabelValues(c.Name).Set(1)
+	} else {
+		pr.up.WithLabelValues(c.Name).Set(0)
+		pr.failures.WithLabelValues(c.Name, string(c.Reason)).Inc()
+	}
+	// Failed results don't measure how long the check takes, so are left out of latency
+	if c.Success {
+		pr.latency.WithLabelValues(c.Name, "total").Observe(c.Latency.Seconds())
+	}
+	if c.Success && c.Timing != nil {
+		// A reused connection skips the dns, connect and tls phases, which would otherwise drag
+		// their histograms down to zero
+		for phase, d := range map[string]time.Duration{
+			"dns":     c.Timing.DNSLookup,
+			"connect": c.Timing.TCPConnect,
+			"tls":     c.Timing.TLSHandshake,
+		} {
+			if d > 0 {
+				pr.latency.WithLabelValues(c.Name, phase).Observe(d.Seconds())
+			}
+		}
+		pr.latency.WithLabelValues(c.Name, "ttfb").Observe(c.Timing.FirstByte.Seconds())
+		pr.latency.WithLabelValues(c.Name, "transfer").Observe(c.Timing.Transfer.Seconds())
+	}
+	if !c.CertExpiry.IsZero() {
+		pr.certExpiry.WithLabelValues(c.Name).Set(float64(c.CertDaysRemaining()))
+	}
+	return nil
+}
+
+// Register adds the metrics endpoint to the mux, at PrometheusPath
+func (pr PrometheusReporter) Register(mux *http.ServeMux) {
+	mux.Handle(pr.PrometheusPath, promhttp.HandlerFor(pr.registry, promhttp.HandlerOpts{}))
+}
@@ -0,0 +1,87 @@
+package lib
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusReporter(t *testing.T) {
+	pr := NewPrometheusReporter(ParsedServer{}, "", "/metrics")
+	events := []Event{
+		{Result: CheckResult{Name: "api", Success: true, Latency: 200 * time.Millisecond, Timing: &Timing{FirstByte: 150 * time.Millisecond}}},
+		{Result: CheckResult{Name: "api", Success: false, Reason: ReasonTimeout, Latency: 10 * time.Second}},
+		{Result: CheckResult{Name: "api", Success: false, Reason: ReasonTimeout}},
+		{Result: CheckResult{Name: "site", Success: true, Latency: time.Second, CertExpiry: time.Now().Add(30*24*time.Hour + time.Hour)}},
+	}
+	for _, ev := range events {
+		if err := pr.Report(context.Background(), ev); err != nil {
+			t.Fatalf("Report() returned error: %s", err)
+		}
+	}
+	mux := http.NewServeMux()
+	pr.Register(mux)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	metrics := w.Body.String()
+	for _, want := range []string{
+		`bantay_check_up{check="api"} 0`,
+		`bantay_check_up{check="site"} 1`,
+		`bantay_check_failures_total{check="api",reason="timeout"} 2`,
+		`bantay_check_latency_seconds_count{check="api",phase="total"} 1`,
+		`bantay_check_latency_seconds_sum{check="api",phase="ttfb"} 0.15`,
+		`bantay_check_latency_seconds_count{check="site",phase="total"} 1`,
+		`bantay_check_cert_expiry_days{check="site"} 30`,
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("GET /metrics doesn't contain %s", want)
+		}
+	}
+	// Only http checks measure phases
+	if strings.Contains(metrics, `check="site",phase="ttfb"`) {
+		t.Error("GET /metrics has phases of a check without timing")
+	}
+}
+
+func TestPrometheusReporterSkipsReusedConnectionPhases(t *testing.T) {
+	pr := NewPrometheusReporter(ParsedServer{}, "", "/metrics")
+	timings := []*Timing{
+		{DNSLookup: 10 * time.Millisecond, TCPConnect: 20 * time.Millisecond, TLSHandshake: 30 * time.Millisecond, FirstByte: 100 * time.Millisecond},
+		{FirstByte: 50 * time.Millisecond},
+	}
+	for _, timing := range timings {
+		pr.Report(context.Background(), Event{Result: CheckResult{Name: "api", Success: true, Latency: 200 * time.Millisecond, Timing: timing}})
+	}
+	mux := http.NewServeMux()
+	pr.Register(mux)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	metrics := w.Body.String()
+	for _, want := range []string{
+		`bantay_check_latency_seconds_count{check="api",phase="dns"} 1`,
+		`bantay_check_latency_seconds_count{check="api",phase="connect"} 1`,
+		`bantay_check_latency_seconds_count{check="api",phase="tls"} 1`,
+		`bantay_check_latency_seconds_count{check="api",phase="ttfb"} 2`,
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("GET /metrics doesn't contain %s", want)
+		}
+	}
+}
+
+func TestParseYAMLPrometheusReporter(t *testing.T) {
+	config, err := ParseYAML([]byte("server:\n  listen: :8080\nreporters:\n  - type: prometheus\n"))
+	if err != nil {
+		t.Fatalf("ParseYAML() returned error: %s", err)
+	}
+	pr, ok := config.ExportedReporters[0].(PrometheusReporter)
+	if !ok || pr.PrometheusPath != "/metrics" || len(pr.PrometheusListen) != 0 {
+		t.Errorf("ParseYAML() reporter = %+v, want a prometheus reporter on the server listener at /metrics", config.ExportedReporters[0])
+	}
+	if _, err := ParseYAML([]byte("reporters:\n  - type: prometheus\n")); err == nil {
+		t.Error("ParseYAML() of a prometheus reporter without any listener returned no error")
+	}
+}
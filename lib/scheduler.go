@@ -0,0 +1,84 @@
+package lib
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/KixPanganiban/bantay/log"
+)
+
+// Scheduler runs every Check independently on its own Interval, delaying each run by a random
+// jitter so that checks sharing an interval don't all fire at once
+type Scheduler struct {
+	Checks    []Check
+	Pool      *WorkerPool
+	Reporters []Reporter
+	Store     *IncidentStore
+	// Jitter is the most each run is delayed by. When zero, a tenth of the Check Interval is used.
+	Jitter time.Duration
+}
+
+// NewScheduler returns a Scheduler for the given checks, running them on the WorkerPool and
+// reporting results to the given reporters
+func NewScheduler(cs []Check, pool *WorkerPool, r []Reporter, store *IncidentStore, jitter time.Duration) *Scheduler {
+	return &Scheduler{
+		Checks:    cs,
+		Pool:      pool,
+		Reporters: r,
+		Store:     store,
+		Jitter:    jitter,
+	}
+}
+
+// Run schedules every Check and blocks until the context is done and all running checks returned
+func (s *Scheduler) Run(ctx context.Context) {
+	s.Pool.Start(ctx)
+	var wg sync.WaitGroup
+	for i, c := range s.Checks {
+		wg.Add(1)
+		go func(i int, c Check) {
+			defer wg.Done()
+			s.schedule(ctx, c, rand.New(rand.NewSource(time.Now().UnixNano()+int64(i))))
+		}(i, c)
+	}
+	wg.Wait()
+}
+
+// schedule runs the Check every Interval, plus jitter, until the context is done
+func (s *Scheduler) schedule(ctx context.Context, c Check, rnd *rand.Rand) {
+	interval := c.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	jitter := s.Jitter
+	if jitter <= 0 {
+		jitter = interval / 10
+	}
+	base := time.Now()
+	delay := time.Duration(rnd.Int63n(int64(jitter) + 1))
+	log.Debugf("[%s] Scheduled every %s, first run in %s.", c.Name, interval, delay)
+	for n := 1; ; n++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		res, err := s.Pool.Run(ctx, c)
+		if err != nil {
+			return
+		}
+		reportResult(ctx, c, res, s.Reporters, s.Store)
+		// Runs are kept on the interval from the start, so the jitter doesn't add up between them,
+		// and any missed while this one ran are skipped
+		now := time.Now()
+		for base.Add(time.Duration(n) * interval).Before(now) {
+			n++
+		}
+		delay = time.Until(base.Add(time.Duration(n)*interval + time.Duration(rnd.Int63n(int64(jitter)+1))))
+		if delay < 0 {
+			delay = 0
+		}
+	}
+}
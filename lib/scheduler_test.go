@@ -0,0 +1,50 @@
+package lib
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingChecker always succeeds, counting how many times it was run
+type countingChecker struct {
+	mu   sync.Mutex
+	runs int
+}
+
+func (cc *countingChecker) Validate(c Check) error {
+	return nil
+}
+
+func (cc *countingChecker) Check(ctx context.Context, c Check) CheckResult {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.runs++
+	return CheckResult{Name: c.Name, Success: true}
+}
+
+func TestSchedulerRunsEachCheckOnItsInterval(t *testing.T) {
+	counter := &countingChecker{}
+	RegisterChecker("counting", counter)
+	defer delete(checkers, "counting")
+	checks := []Check{
+		{Name: "fast", Type: "counting", Interval: 20 * time.Millisecond},
+		{Name: "slow", Type: "counting", Interval: time.Hour},
+	}
+	store := NewIncidentStore()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	NewScheduler(checks, NewWorkerPool(2, 0), nil, store, 15*time.Millisecond).Run(ctx)
+
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+	if _, ok := store.State("fast"); !ok {
+		t.Error("fast check was never tracked")
+	}
+	// fast runs every 20ms on average despite the jitter, about 50 times, and slow only once.
+	// Jitter adding up between runs would average 27.5ms, about 36 runs.
+	if counter.runs < 45 || counter.runs > 53 {
+		t.Errorf("checks ran %d times in 1s, want about 50 runs of fast and 1 of slow", counter.runs)
+	}
+}
@@ -0,0 +1,203 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/KixPanganiban/bantay/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+// DefaultStateSaveInterval is how often check states are saved when the server doesn't set it
+const DefaultStateSaveInterval = 10 * time.Second
+
+// stateBucket is the BoltDB bucket holding check states, keyed by check name
+var stateBucket = []byte("states")
+
+// StateBackend persists the state of every check, so that ongoing incidents survive restarts
+type StateBackend interface {
+	Load() ([]CheckState, error)
+	Save([]CheckState) error
+	Close() error
+}
+
+// NewStateBackend returns the StateBackend configured under server state
+func NewStateBackend(sc ParsedState) (StateBackend, error) {
+	switch sc.Type {
+	case "file":
+		{
+			return FileStateBackend{Path: sc.Path}, nil
+		}
+	case "bolt":
+		{
+			return NewBoltStateBackend(sc.Path)
+		}
+	}
+	return nil, fmt.Errorf("unknown state type %s", sc.Type)
+}
+
+// validateState ensures the server state config names a known backend and where to keep it
+func validateState(sc ParsedState) error {
+	if len(sc.Type) == 0 {
+		return nil
+	}
+	if sc.Type != "file" && sc.Type != "bolt" {
+		return fmt.Errorf("unknown state type %s, expected file or bolt", sc.Type)
+	}
+	if len(sc.Path) == 0 {
+		return errors.New("state path is required")
+	}
+	if sc.SaveInterval < 0 {
+		return errors.New("state save_interval can't be negative")
+	}
+	return validateDuration("state save_interval", sc.SaveInterval, time.Second)
+}
+
+// FileStateBackend implements StateBackend by keeping check states in a JSON file
+type FileStateBackend struct {
+	Path string
+}
+
+// Load reads check states from the file, returning none if it doesn't exist yet
+func (fb FileStateBackend) Load() ([]CheckState, error) {
+	b, err := ioutil.ReadFile(fb.Path)
+	if os.IsNotExist(err) {
+		return []CheckState{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var states []CheckState
+	if err := json.Unmarshal(b, &states); err != nil {
+		return nil, fmt.Errorf("can't parse state file %s: %s", fb.Path, err.Error())
+	}
+	return states, nil
+}
+
+// Save writes check states to a temporary file then moves it over the file, so that a crash
+// mid-write never leaves a truncated file behind
+func (fb FileStateBackend) Save(states []CheckState) error {
+	b, err := json.Marshal(states)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(fb.Path), filepath.Base(fb.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fb.Path)
+}
+
+// Close does nothing, as the file is only open while loading or saving
+func (fb FileStateBackend) Close() error {
+	return nil
+}
+
+// BoltStateBackend implements StateBackend by keeping check states in an embedded BoltDB file
+type BoltStateBackend struct {
+	db *bolt.DB
+}
+
+// NewBoltStateBackend opens, or creates, the BoltDB file at the given path
+func NewBoltStateBackend(path string) (BoltStateBackend, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return BoltStateBackend{}, fmt.Errorf("can't open state database %s: %s", path, err.Error())
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(stateBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return BoltStateBackend{}, err
+	}
+	return BoltStateBackend{db: db}, nil
+}
+
+// Load reads every check state from the database
+func (bb BoltStateBackend) Load() ([]CheckState, error) {
+	states := []CheckState{}
+	err := bb.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(stateBucket).ForEach(func(k []byte, v []byte) error {
+			var state CheckState
+			if err := json.Unmarshal(v, &state); err != nil {
+				return fmt.Errorf("can't parse state of %s: %s", k, err.Error())
+			}
+			states = append(states, state)
+			return nil
+		})
+	})
+	return states, err
+}
+
+// Save replaces the check states in the database in a single transaction
+func (bb BoltStateBackend) Save(states []CheckState) error {
+	return bb.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(stateBucket); err != nil {
+			return err
+		}
+		bucket, err := tx.CreateBucket(stateBucket)
+		if err != nil {
+			return err
+		}
+		for _, state := range states {
+			v, err := json.Marshal(state)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(state.Name), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close closes the database
+func (bb BoltStateBackend) Close() error {
+	return bb.db.Close()
+}
+
+// PersistState saves the states in the IncidentStore to the StateBackend every interval, if any
+// changed, until the context is done, then saves them one last time
+func PersistState(ctx context.Context, store *IncidentStore, backend StateBackend, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultStateSaveInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	save := func() {
+		states, dirty := store.Snapshot()
+		if !dirty {
+			return
+		}
+		if err := backend.Save(states); err != nil {
+			log.Warnf("Unable to save check states: %s", err.Error())
+			store.markDirty()
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			save()
+			return
+		case <-ticker.C:
+			save()
+		}
+	}
+}
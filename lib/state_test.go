@@ -0,0 +1,128 @@
+package lib
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// tempDir creates a temporary directory, which the caller removes
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "bantay")
+	if err != nil {
+		t.Fatalf("can't create temporary directory: %s", err)
+	}
+	return dir
+}
+
+func TestStateBackendRoundTrip(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	states := []CheckState{{
+		Name:                "a",
+		State:               "down",
+		LastTransition:      start,
+		LastResult:          CheckResult{Name: "a", Success: false, Reason: ReasonTimeout, Message: "timed out"},
+		ConsecutiveFailures: 3,
+		Incident:            &Incident{Start: start, Down: true, FailedCount: 3, Attempts: []Attempt{{Time: start, Reason: ReasonTimeout}}},
+	}}
+	for _, stateType := range []string{"file", "bolt"} {
+		t.Run(stateType, func(t *testing.T) {
+			sc := ParsedState{Type: stateType, Path: filepath.Join(dir, "state."+stateType)}
+			backend, err := NewStateBackend(sc)
+			if err != nil {
+				t.Fatalf("NewStateBackend() returned error: %s", err)
+			}
+			if loaded, err := backend.Load(); err != nil || len(loaded) != 0 {
+				t.Errorf("Load() of a new backend = %v, %v, want no states", loaded, err)
+			}
+			if err := backend.Save(states); err != nil {
+				t.Fatalf("Save() returned error: %s", err)
+			}
+			backend.Close()
+
+			backend, err = NewStateBackend(sc)
+			if err != nil {
+				t.Fatalf("NewStateBackend() returned error: %s", err)
+			}
+			defer backend.Close()
+			loaded, err := backend.Load()
+			if err != nil || len(loaded) != 1 {
+				t.Fatalf("Load() = %v, %v, want the saved state", loaded, err)
+			}
+			s := loaded[0]
+			if s.Name != "a" || s.State != "down" || !s.LastTransition.Equal(start) || s.LastResult.Reason != ReasonTimeout || s.ConsecutiveFailures != 3 {
+				t.Errorf("Load() = %+v, want the saved state", s)
+			}
+			if s.Incident == nil || !s.Incident.Down || s.Incident.FailedCount != 3 || len(s.Incident.Attempts) != 1 {
+				t.Errorf("Load() incident = %+v, want the saved incident", s.Incident)
+			}
+		})
+	}
+}
+
+func TestFileStateBackendInvalidFile(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+	ioutil.WriteFile(path, []byte("{"), 0600)
+	if _, err := (FileStateBackend{Path: path}).Load(); err == nil {
+		t.Error("Load() of an invalid file returned no error")
+	}
+}
+
+func TestPersistStateSavesOnShutdown(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	backend := FileStateBackend{Path: filepath.Join(dir, "state.json")}
+	store := NewIncidentStore()
+	store.Track(Check{Name: "a"}, CheckResult{Name: "a", Success: false})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	PersistState(ctx, store, backend, time.Hour)
+	loaded, err := backend.Load()
+	if err != nil || len(loaded) != 1 || loaded[0].State != "down" {
+		t.Errorf("Load() after shutdown = %v, %v, want the tracked state", loaded, err)
+	}
+}
+
+func TestValidateState(t *testing.T) {
+	tests := []struct {
+		name  string
+		state ParsedState
+		valid bool
+	}{
+		{"unset", ParsedState{}, true},
+		{"file", ParsedState{Type: "file", Path: "state.json", SaveInterval: 30 * time.Second}, true},
+		{"unknown type", ParsedState{Type: "redis", Path: "state"}, false},
+		{"no path", ParsedState{Type: "bolt"}, false},
+	}
+	for _, tt := range tests {
+		if err := validateState(tt.state); (err == nil) != tt.valid {
+			t.Errorf("validateState() of %s returned %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestIncidentStoreRestore(t *testing.T) {
+	store := NewIncidentStore()
+	states := []CheckState{
+		{Name: "a", State: "down", Incident: &Incident{Down: true}},
+		{Name: "removed", State: "up"},
+	}
+	store.Restore(states, []Check{{Name: "a"}})
+	if state, ok := store.State("a"); !ok || state.State != "down" || state.Incident == nil {
+		t.Errorf("State(a) = %+v, want the restored down state", state)
+	}
+	if _, ok := store.State("removed"); ok {
+		t.Error("State() of a check that is no longer configured was restored")
+	}
+	ev := store.Track(Check{Name: "a"}, CheckResult{Name: "a", Success: true})
+	if ev.Type != EventRecovered {
+		t.Errorf("Track() after Restore = %q, want the restored incident to recover", ev.Type)
+	}
+}
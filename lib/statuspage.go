@@ -0,0 +1,278 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/KixPanganiban/bantay/log"
+)
+
+// statusPageDays is the number of days of uptime shown for every check on the status page
+const statusPageDays = 90
+
+// statusPageCacheTTL is how long a rendered status page is served before it is rendered again,
+// so that a busy page doesn't read the whole history on every request
+const statusPageCacheTTL = 30 * time.Second
+
+// StatusPage serves an HTML page with the current state and daily uptime of every check that
+// isn't Internal, listed under their Group
+type StatusPage struct {
+	Title  string
+	Logo   string
+	Checks []Check
+	Store  *IncidentStore
+	// History is used for the uptime bars, which aren't shown when it is nil
+	History  *HistoryStore
+	mu       sync.Mutex
+	rendered []byte
+	expires  time.Time
+}
+
+// NewStatusPage returns the StatusPage configured under server status_page
+func NewStatusPage(sc ParsedStatusPage, cs []Check, store *IncidentStore, history *HistoryStore) *StatusPage {
+	title := sc.Title
+	if len(title) == 0 {
+		title = "Status"
+	}
+	return &StatusPage{
+		Title:   title,
+		Logo:    sc.Logo,
+		Checks:  cs,
+		Store:   store,
+		History: history,
+	}
+}
+
+// Register adds the StatusPage to the mux, at the root
+func (sp *StatusPage) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/", sp.serve)
+}
+
+// serve serves the rendered page, rendering it again once the cached one expired. Other requests
+// are served the expired page in the meantime, rather than wait for it or render it too.
+func (sp *StatusPage) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	sp.mu.Lock()
+	rendered := sp.rendered
+	refresh := rendered == nil || time.Now().After(sp.expires)
+	if refresh {
+		sp.expires = time.Now().Add(statusPageCacheTTL)
+	}
+	sp.mu.Unlock()
+	if refresh {
+		fresh, err := sp.render(time.Now())
+		sp.mu.Lock()
+		if err != nil {
+			log.Warnf("Unable to render status page: %s", err.Error())
+			// Let the next request try again
+			sp.expires = time.Time{}
+		} else {
+			sp.rendered, rendered = fresh, fresh
+		}
+		sp.mu.Unlock()
+	}
+	if rendered == nil {
+		http.Error(w, "Unable to render status page", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(rendered)
+}
+
+// statusPageData is what the status page template is rendered with
+type statusPageData struct {
+	Title     string
+	Logo      string
+	State     string
+	Incidents []statusPageIncident
+	Groups    []statusPageGroup
+	Days      int
+	Updated   time.Time
+}
+
+type statusPageIncident struct {
+	Name  string
+	State string
+	Since time.Time
+}
+
+type statusPageGroup struct {
+	Name   string
+	Checks []statusPageCheck
+}
+
+type statusPageCheck struct {
+	Name   string
+	State  string
+	Uptime string
+	Days   []statusPageDay
+}
+
+type statusPageDay struct {
+	Class string
+	Title string
+}
+
+// render renders the page as of now
+func (sp *StatusPage) render(now time.Time) ([]byte, error) {
+	data := statusPageData{Title: sp.Title, Logo: sp.Logo, State: "up", Days: statusPageDays, Updated: now}
+	groups := map[string]int{}
+	for _, c := range sp.Checks {
+		if c.Internal {
+			continue
+		}
+		check := statusPageCheck{Name: c.Name, State: "pending"}
+		if state, ok := sp.Store.State(c.Name); ok {
+			check.State = state.State
+			switch state.State {
+			case "down":
+				{
+					since := state.LastTransition
+					if state.Incident != nil {
+						since = state.Incident.Start
+					}
+					data.Incidents = append(data.Incidents, statusPageIncident{Name: c.Name, State: "down", Since: since})
+					data.State = "down"
+				}
+			case "degraded":
+				{
+					data.Incidents = append(data.Incidents, statusPageIncident{Name: c.Name, State: "degraded", Since: state.LastTransition})
+					if data.State == "up" {
+						data.State = "degraded"
+					}
+				}
+			}
+		}
+		if sp.History != nil {
+			days, err := DailyReports(sp.History, c.Name, statusPageDays, now)
+			if err != nil {
+				return nil, err
+			}
+			var monitored, downtime time.Duration
+			for _, day := range days {
+				monitored += day.Monitored
+				downtime += day.Downtime
+				check.Days = append(check.Days, newStatusPageDay(day, now.AddDate(0, 0, len(check.Days)+1-statusPageDays)))
+			}
+			if monitored > 0 {
+				check.Uptime = fmt.Sprintf("%.2f%%", 100*float64(monitored-downtime)/float64(monitored))
+			}
+		}
+		i, ok := groups[c.Group]
+		if !ok {
+			i = len(data.Groups)
+			groups[c.Group] = i
+			data.Groups = append(data.Groups, statusPageGroup{Name: c.Group})
+		}
+		data.Groups[i].Checks = append(data.Groups[i].Checks, check)
+	}
+	var b bytes.Buffer
+	if err := statusPageTemplate.Execute(&b, data); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// newStatusPageDay returns the uptime bar of the given day
+func newStatusPageDay(day CheckReport, date time.Time) statusPageDay {
+	label := date.Format("Jan 2, 2006")
+	switch {
+	case day.Results == 0:
+		{
+			return statusPageDay{Class: "none", Title: label + ": no data"}
+		}
+	case day.Downtime == 0:
+		{
+			return statusPageDay{Class: "up", Title: label + ": no downtime"}
+		}
+	case day.Uptime >= 99:
+		{
+			return statusPageDay{Class: "partial", Title: fmt.Sprintf("%s: %s uptime, down for %s", label, day.UptimeString(), day.Downtime.Round(time.Second))}
+		}
+	}
+	return statusPageDay{Class: "down", Title: fmt.Sprintf("%s: %s uptime, down for %s", label, day.UptimeString(), day.Downtime.Round(time.Second))}
+}
+
+var statusPageTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"time": func(t time.Time) string { return t.Format(time.RFC1123) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; color: #333; max-width: 860px; margin: 0 auto; padding: 24px; }
+header { text-align: center; margin-bottom: 24px; }
+header img { max-height: 64px; }
+.banner { padding: 16px; border-radius: 4px; color: #fff; font-weight: bold; margin-bottom: 24px; }
+.banner.up { background: #36a64f; }
+.banner.degraded { background: #daa038; }
+.banner.down { background: #bd2f2f; }
+.incident { border-left: 4px solid #bd2f2f; padding: 8px 12px; margin-bottom: 8px; background: #fafafa; }
+.incident.degraded { border-color: #daa038; }
+.group { border: 1px solid #ddd; border-radius: 4px; margin-bottom: 24px; }
+.group h2 { font-size: 1em; margin: 0; padding: 12px 16px; background: #f5f5f5; border-bottom: 1px solid #ddd; }
+.check { padding: 12px 16px; border-bottom: 1px solid #eee; }
+.check:last-child { border-bottom: none; }
+.check .name { font-weight: bold; }
+.check .state { float: right; }
+.state.up { color: #36a64f; }
+.state.degraded { color: #daa038; }
+.state.down { color: #bd2f2f; }
+.state.pending { color: #999; }
+.bars { display: flex; margin-top: 8px; height: 28px; }
+.bars span { flex: 1; margin-right: 1px; border-radius: 1px; }
+.bars .up { background: #36a64f; }
+.bars .partial { background: #daa038; }
+.bars .down { background: #bd2f2f; }
+.bars .none { background: #ddd; }
+.legend { display: flex; justify-content: space-between; font-size: 0.8em; color: #999; }
+footer { text-align: center; font-size: 0.8em; color: #999; }
+</style>
+</head>
+<body>
+<header>
+{{- if .Logo}}
+<img src="{{.Logo}}" alt="{{.Title}}">
+{{- end}}
+<h1>{{.Title}}</h1>
+</header>
+{{- if eq .State "up"}}
+<div class="banner up">All systems operational</div>
+{{- else if eq .State "degraded"}}
+<div class="banner degraded">Some systems are degraded</div>
+{{- else}}
+<div class="banner down">Some systems are down</div>
+{{- end}}
+{{- range .Incidents}}
+<div class="incident {{.State}}"><strong>{{.Name}}</strong> {{if eq .State "down"}}is down{{else}}is degraded{{end}} since {{time .Since}}</div>
+{{- end}}
+{{- range .Groups}}
+<div class="group">
+{{- if .Name}}
+<h2>{{.Name}}</h2>
+{{- end}}
+{{- range .Checks}}
+<div class="check">
+<span class="name">{{.Name}}</span>
+<span class="state {{.State}}">{{.State}}</span>
+{{- if .Days}}
+<div class="bars">{{range .Days}}<span class="{{.Class}}" title="{{.Title}}"></span>{{end}}</div>
+<div class="legend"><span>{{$.Days}} days ago</span><span>{{if .Uptime}}{{.Uptime}} uptime{{end}}</span><span>Today</span></div>
+{{- end}}
+</div>
+{{- end}}
+</div>
+{{- end}}
+<footer>Updated {{time .Updated}}</footer>
+</body>
+</html>
+`))
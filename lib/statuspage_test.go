@@ -0,0 +1,105 @@
+package lib
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDailyReports(t *testing.T) {
+	hs, dir := newTestHistoryStore(t)
+	defer os.RemoveAll(dir)
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	twoDaysAgo := today.AddDate(0, 0, -2)
+	hs.results = []HistoryRecord{
+		{Time: twoDaysAgo.Add(12 * time.Hour), Result: CheckResult{Name: "a", Success: false}},
+		{Time: twoDaysAgo.Add(18 * time.Hour), Result: CheckResult{Name: "a", Success: true}},
+	}
+	// Down for the last 6 hours of the day, until a minute into the next
+	hs.incidents = map[string][]Incident{"a": {{Start: twoDaysAgo.Add(18 * time.Hour), End: twoDaysAgo.Add(24*time.Hour + time.Minute), Down: true}}}
+	hs.Flush()
+
+	reports, err := DailyReports(hs, "a", 3, now)
+	if err != nil || len(reports) != 3 {
+		t.Fatalf("DailyReports() = %+v, %v, want 3 days", reports, err)
+	}
+	if r := reports[0]; r.Results != 2 || r.Monitored != 12*time.Hour || r.Downtime != 6*time.Hour || r.Uptime != 50 {
+		t.Errorf("DailyReports() two days ago = %+v, want half of the 12 monitored hours down", r)
+	}
+	if r := reports[1]; r.Results != 0 || r.Downtime != 0 {
+		t.Errorf("DailyReports() yesterday = %+v, want no data", r)
+	}
+	if r := reports[2]; r.Results != 0 {
+		t.Errorf("DailyReports() today = %+v, want no data", r)
+	}
+}
+
+// newTestStatusPage returns a StatusPage of a public check that is down, a degraded one, and an
+// internal one
+func newTestStatusPage(history *HistoryStore) *StatusPage {
+	checks := []Check{
+		{Name: "api", Group: "Backend"},
+		{Name: "web", Group: "Frontend"},
+		{Name: "db", Group: "Backend", Internal: true},
+	}
+	store := NewIncidentStore()
+	store.Track(checks[0], CheckResult{Name: "api", Success: false})
+	store.Track(checks[1], CheckResult{Name: "web", Success: true, Degraded: true})
+	store.Track(checks[2], CheckResult{Name: "db", Success: false})
+	return NewStatusPage(ParsedStatusPage{Enabled: true, Title: "Acme Status"}, checks, store, history)
+}
+
+func TestStatusPage(t *testing.T) {
+	hs, dir := newTestHistoryStore(t)
+	defer os.RemoveAll(dir)
+	hs.results = []HistoryRecord{{Time: time.Now(), Result: CheckResult{Name: "web", Success: true}}}
+	hs.Flush()
+	mux := http.NewServeMux()
+	newTestStatusPage(hs).Register(mux)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	page := w.Body.String()
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("GET / returned %d with Content-Type %q, want an HTML page", w.Code, w.Header().Get("Content-Type"))
+	}
+	for _, want := range []string{"<title>Acme Status</title>", "Some systems are down", "<strong>api</strong> is down", "<strong>web</strong> is degraded", "<h2>Backend</h2>", "<h2>Frontend</h2>", "100.00% uptime"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("GET / doesn't contain %q", want)
+		}
+	}
+	if strings.Contains(page, "db") {
+		t.Error("GET / shows the internal db check")
+	}
+	if bars := strings.Count(page, `<span class="none"`) + strings.Count(page, `<span class="up"`); bars != 2*statusPageDays {
+		t.Errorf("GET / shows %d uptime bars, want %d for each public check", bars, statusPageDays)
+	}
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET /missing returned %d, want 404", w.Code)
+	}
+}
+
+func TestStatusPageCache(t *testing.T) {
+	sp := newTestStatusPage(nil)
+	serve := func() string {
+		w := httptest.NewRecorder()
+		sp.serve(w, httptest.NewRequest("GET", "/", nil))
+		return w.Body.String()
+	}
+	first := serve()
+	sp.Store.Track(Check{Name: "api"}, CheckResult{Name: "api", Success: true})
+	if serve() != first {
+		t.Error("GET / rendered the page again before the cached one expired")
+	}
+	sp.mu.Lock()
+	sp.expires = time.Now().Add(-time.Second)
+	sp.mu.Unlock()
+	if page := serve(); page == first || strings.Contains(page, "<strong>api</strong> is down") {
+		t.Error("GET / didn't render the page again once the cached one expired")
+	}
+}